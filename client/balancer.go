@@ -0,0 +1,12 @@
+package client
+
+import (
+	"github.com/orchestd/transport/discoveryService"
+)
+
+// Balancer picks the instance of a service a call is sent to. The returned done func is called once the call
+// completed, so strategies can track outstanding requests, it may be nil when they don't. Pick is called
+// concurrently.
+type Balancer interface {
+	Pick(serviceName string, instances []discoveryService.Instance) (instance discoveryService.Instance, done func())
+}
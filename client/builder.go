@@ -2,7 +2,9 @@ package client
 
 import (
 	"github.com/orchestd/dependencybundler/interfaces/configuration"
+	"github.com/orchestd/servicereply"
 	"net/http"
+	"time"
 )
 
 // HTTPHandler is just an alias to http.RoundTriper.RoundTrip function
@@ -12,11 +14,34 @@ type HTTPHandler func(*http.Request) (*http.Response, error)
 // and/or alter a response before it's returned to the caller
 type HTTPClientInterceptor func(*http.Request, HTTPHandler) (*http.Response, error)
 
+// ResolveFailureHook is called with the reply of a call that failed because its target service couldn't be
+// resolved to an instance, the request never reached the interceptors
+type ResolveFailureHook func(serviceName string, sRep servicereply.ServiceReply)
+
 // HTTPClientBuilder is a builder interface to build http.Client with interceptors
 type HTTPClientBuilder interface {
 	SetConfig(conf configuration.Config) HTTPClientBuilder
 	AddInterceptors(...HTTPClientInterceptor) HTTPClientBuilder
+	// SetReplyContentType sets the Accept header of internal calls, see replyCodec for the supported content types
+	SetReplyContentType(contentType string) HTTPClientBuilder
 	WithPreconfiguredClient(*http.Client) HTTPClientBuilder
+	// SetTLS configures the TLS of the calls, certificates are verified against the system authorities when it
+	// isn't set. With a preconfigured client its transport must be an *http.Transport, which is cloned.
+	SetTLS(settings TLSSettings) HTTPClientBuilder
+	// SetTransport tunes connection pooling and HTTP/2, like SetTLS it requires an *http.Transport
+	SetTransport(settings TransportSettings) HTTPClientBuilder
+	// SetBalancer sets the strategy used to pick between the instances returned by a discoveryService.Resolver,
+	// round-robin by default
+	SetBalancer(balancer Balancer) HTTPClientBuilder
+	// SetServiceBalancer overrides the balancer of a single target service
+	SetServiceBalancer(serviceName string, balancer Balancer) HTTPClientBuilder
+	// SetTimeout bounds every call, there is no timeout by default or when it is zero or negative. The
+	// "clientTimeOutMs" configuration key overrides it.
+	SetTimeout(timeout time.Duration) HTTPClientBuilder
+	// SetServiceTimeout overrides the timeout of the calls to a single target service, the "clientServiceTimeOutsMs"
+	// configuration map of service name to milliseconds overrides it. Use WithCallTimeout for a single call.
+	SetServiceTimeout(serviceName string, timeout time.Duration) HTTPClientBuilder
+	AddResolveFailureHooks(...ResolveFailureHook) HTTPClientBuilder
 	Build() (HttpClient, error)
 }
 
@@ -0,0 +1,123 @@
+package balancers
+
+import (
+	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/discoveryService"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+func noop() {}
+
+type roundRobin struct {
+	counters sync.Map
+}
+
+// RoundRobin cycles through the instances of every service
+func RoundRobin() client.Balancer {
+	return &roundRobin{}
+}
+
+func (r *roundRobin) Pick(serviceName string, instances []discoveryService.Instance) (discoveryService.Instance, func()) {
+	v, _ := r.counters.LoadOrStore(serviceName, new(uint64))
+	n := atomic.AddUint64(v.(*uint64), 1)
+	return instances[(n-1)%uint64(len(instances))], noop
+}
+
+type weightedRandom struct {
+}
+
+// WeightedRandom picks instances randomly in proportion to their weight, instances without a weight count as 1
+func WeightedRandom() client.Balancer {
+	return weightedRandom{}
+}
+
+func (weightedRandom) Pick(_ string, instances []discoveryService.Instance) (discoveryService.Instance, func()) {
+	total := 0
+	for _, instance := range instances {
+		total += weight(instance)
+	}
+	n := rand.Intn(total)
+	for _, instance := range instances {
+		if n -= weight(instance); n < 0 {
+			return instance, noop
+		}
+	}
+	return instances[len(instances)-1], noop
+}
+
+func weight(instance discoveryService.Instance) int {
+	if instance.Weight <= 0 {
+		return 1
+	}
+	return instance.Weight
+}
+
+// outstanding counts the calls in progress per instance address
+type outstanding struct {
+	counts sync.Map
+}
+
+func (o *outstanding) count(instance discoveryService.Instance) int64 {
+	if v, ok := o.counts.Load(instance.Address); ok {
+		return atomic.LoadInt64(v.(*int64))
+	}
+	return 0
+}
+
+func (o *outstanding) track(instance discoveryService.Instance) (discoveryService.Instance, func()) {
+	v, _ := o.counts.LoadOrStore(instance.Address, new(int64))
+	atomic.AddInt64(v.(*int64), 1)
+	var once sync.Once
+	return instance, func() {
+		once.Do(func() {
+			atomic.AddInt64(v.(*int64), -1)
+		})
+	}
+}
+
+type leastOutstanding struct {
+	outstanding
+}
+
+// LeastOutstanding picks the instance with the fewest calls in progress, ties are broken randomly
+func LeastOutstanding() client.Balancer {
+	return &leastOutstanding{}
+}
+
+func (l *leastOutstanding) Pick(_ string, instances []discoveryService.Instance) (discoveryService.Instance, func()) {
+	offset := rand.Intn(len(instances))
+	best := instances[offset]
+	for i := 1; i < len(instances); i++ {
+		instance := instances[(offset+i)%len(instances)]
+		if l.count(instance) < l.count(best) {
+			best = instance
+		}
+	}
+	return l.track(best)
+}
+
+type powerOfTwoChoices struct {
+	outstanding
+}
+
+// PowerOfTwoChoices picks two random instances and uses the one with fewer calls in progress
+func PowerOfTwoChoices() client.Balancer {
+	return &powerOfTwoChoices{}
+}
+
+func (p *powerOfTwoChoices) Pick(_ string, instances []discoveryService.Instance) (discoveryService.Instance, func()) {
+	if len(instances) == 1 {
+		return p.track(instances[0])
+	}
+	i := rand.Intn(len(instances))
+	j := rand.Intn(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+	if p.count(instances[j]) < p.count(instances[i]) {
+		i = j
+	}
+	return p.track(instances[i])
+}
@@ -0,0 +1,68 @@
+package balancers
+
+import (
+	"github.com/orchestd/transport/discoveryService"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+var instances = []discoveryService.Instance{{Address: "a"}, {Address: "b", Weight: 3}, {Address: "c"}}
+
+func Test_RoundRobin(t *testing.T) {
+	convey.Convey("Given a round robin balancer", t, func() {
+		b := RoundRobin()
+		var picked []string
+		for i := 0; i < 4; i++ {
+			instance, done := b.Pick("service", instances)
+			done()
+			picked = append(picked, instance.Address)
+		}
+		convey.So(picked, convey.ShouldResemble, []string{"a", "b", "c", "a"})
+		instance, _ := b.Pick("other", instances)
+		convey.So(instance.Address, convey.ShouldEqual, "a")
+	})
+}
+
+func Test_WeightedRandom(t *testing.T) {
+	convey.Convey("Given a weighted random balancer", t, func() {
+		counts := map[string]int{}
+		for i := 0; i < 5000; i++ {
+			instance, _ := WeightedRandom().Pick("service", instances)
+			counts[instance.Address]++
+		}
+		convey.So(counts["b"], convey.ShouldBeGreaterThan, counts["a"]+counts["c"])
+	})
+}
+
+func Test_Outstanding(t *testing.T) {
+	convey.Convey("Given balancers tracking outstanding calls", t, func() {
+		convey.Convey("least outstanding spreads concurrent calls", func() {
+			b := LeastOutstanding()
+			seen := map[string]bool{}
+			var dones []func()
+			for i := 0; i < 3; i++ {
+				instance, done := b.Pick("service", instances)
+				seen[instance.Address] = true
+				dones = append(dones, done)
+			}
+			convey.So(seen, convey.ShouldHaveLength, 3)
+			for _, done := range dones {
+				done()
+				done()
+			}
+			convey.So(b.(*leastOutstanding).count(instances[0]), convey.ShouldEqual, 0)
+		})
+		convey.Convey("power of two choices avoids the busy instance", func() {
+			b := PowerOfTwoChoices()
+			two := instances[:2]
+			_, done := b.Pick("service", two)
+			busy, _ := b.Pick("service", two)
+			done()
+			for i := 0; i < 10; i++ {
+				instance, done := b.Pick("service", two)
+				convey.So(instance.Address, convey.ShouldNotEqual, busy.Address)
+				done()
+			}
+		})
+	})
+}
@@ -2,19 +2,35 @@ package http
 
 import (
 	"container/list"
-	"crypto/tls"
 	"fmt"
 	"github.com/orchestd/dependencybundler/interfaces/configuration"
 	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/client/http/balancers"
+	"github.com/orchestd/transport/replyCodec"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type httpClientBuilderConfig struct {
 	predefinedClient *http.Client
 	interceptors     []client.HTTPClientInterceptor
 	conf             configuration.Config
+	replyContentType string
+	balancer         client.Balancer
+	serviceBalancers map[string]client.Balancer
+	timeout          *time.Duration
+	serviceTimeouts  map[string]time.Duration
+	tls              *client.TLSSettings
+	transport        *client.TransportSettings
+	resolveHooks     []client.ResolveFailureHook
 }
 
+const (
+	timeoutConfKey         = "clientTimeOutMs"
+	serviceTimeoutsConfKey = "clientServiceTimeOutsMs"
+)
+
 type builderImpl struct {
 	ll *list.List
 }
@@ -41,6 +57,68 @@ func (impl *builderImpl) AddInterceptors(interceptors ...client.HTTPClientInterc
 	return impl
 }
 
+func (impl *builderImpl) SetReplyContentType(contentType string) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.replyContentType = contentType
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetBalancer(balancer client.Balancer) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.balancer = balancer
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetServiceBalancer(serviceName string, balancer client.Balancer) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		if cfg.serviceBalancers == nil {
+			cfg.serviceBalancers = make(map[string]client.Balancer)
+		}
+		cfg.serviceBalancers[serviceName] = balancer
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetTimeout(timeout time.Duration) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.timeout = &timeout
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetServiceTimeout(serviceName string, timeout time.Duration) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		if cfg.serviceTimeouts == nil {
+			cfg.serviceTimeouts = make(map[string]time.Duration)
+		}
+		cfg.serviceTimeouts[serviceName] = timeout
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetTLS(settings client.TLSSettings) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.tls = &settings
+	})
+	return impl
+}
+
+func (impl *builderImpl) SetTransport(settings client.TransportSettings) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.transport = &settings
+	})
+	return impl
+}
+
+func (impl *builderImpl) AddResolveFailureHooks(hooks ...client.ResolveFailureHook) client.HTTPClientBuilder {
+	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
+		cfg.resolveHooks = append(cfg.resolveHooks, hooks...)
+	})
+	return impl
+}
+
 func (impl *builderImpl) WithPreconfiguredClient(client *http.Client) client.HTTPClientBuilder {
 	impl.ll.PushBack(func(cfg *httpClientBuilderConfig) {
 		cfg.predefinedClient = client
@@ -51,6 +129,7 @@ func (impl *builderImpl) WithPreconfiguredClient(client *http.Client) client.HTT
 func (impl *builderImpl) Build() (client.HttpClient, error) {
 	var client = &http.Client{}
 	var conf configuration.Config
+	wrapper := &httpClientWrapper{balancer: balancers.RoundRobin()}
 	if impl != nil {
 		cfg := new(httpClientBuilderConfig)
 		for e := impl.ll.Front(); e != nil; e = e.Next() {
@@ -61,17 +140,90 @@ func (impl *builderImpl) Build() (client.HttpClient, error) {
 			return nil, fmt.Errorf("Cannot initialize Http client without configuration dependency")
 		}
 		conf = cfg.conf
+		if cfg.replyContentType != "" && !replyCodec.IsSupported(cfg.replyContentType) {
+			return nil, fmt.Errorf("unsupported reply content type %s", cfg.replyContentType)
+		}
+		wrapper.replyContentType = cfg.replyContentType
+		if cfg.balancer != nil {
+			wrapper.balancer = cfg.balancer
+		}
+		wrapper.serviceBalancers = cfg.serviceBalancers
+		wrapper.resolveHooks = cfg.resolveHooks
+		if err := cfg.readTimeouts(wrapper); err != nil {
+			return nil, err
+		}
 		if cfg.predefinedClient != nil {
 			client = cfg.predefinedClient
 		}
-		if client.Transport == nil {
-			http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-			client.Transport = http.DefaultTransport
+		transport, err := cfg.roundTripper(client.Transport)
+		if err != nil {
+			return nil, err
 		}
+		client.Transport = transport
 
 		client.Transport = prepareCustomRoundTripper(client.Transport, cfg.interceptors...)
 	}
-	return NewHttpClientWrapper(client, conf)
+	wrapper.client, wrapper.conf = client, conf
+	return wrapper, nil
+}
+
+// roundTripper returns the transport of the client, http.DefaultTransport when it has none. Transports are
+// cloned before they are configured so the configuration stays scoped to the client being built.
+func (cfg *httpClientBuilderConfig) roundTripper(transport http.RoundTripper) (http.RoundTripper, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if cfg.tls == nil && cfg.transport == nil {
+		return transport, nil
+	}
+	httpTransport, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot configure a %T transport", transport)
+	}
+	httpTransport = httpTransport.Clone()
+	if cfg.tls != nil {
+		tlsConfig, err := cfg.tls.Config()
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.TLSClientConfig = tlsConfig
+	}
+	if cfg.transport == nil {
+		return httpTransport, nil
+	}
+	return configureTransport(httpTransport, *cfg.transport)
+}
+
+// readTimeouts sets the timeouts of the builder on the wrapper, the configuration overrides them
+func (cfg *httpClientBuilderConfig) readTimeouts(wrapper *httpClientWrapper) error {
+	if cfg.timeout != nil {
+		wrapper.timeout = *cfg.timeout
+	}
+	if v := cfg.conf.Get(timeoutConfKey); v.IsSet() {
+		ms, err := v.Int()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", timeoutConfKey, err)
+		}
+		wrapper.timeout = time.Duration(ms) * time.Millisecond
+	}
+	wrapper.serviceTimeouts = make(map[string]time.Duration, len(cfg.serviceTimeouts))
+	for serviceName, timeout := range cfg.serviceTimeouts {
+		wrapper.serviceTimeouts[serviceName] = timeout
+	}
+	if v := cfg.conf.Get(serviceTimeoutsConfKey); v.IsSet() {
+		timeouts, err := v.StringMapString()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", serviceTimeoutsConfKey, err)
+		}
+		for serviceName, value := range timeouts {
+			ms, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("cannot read %s of %s: %w", serviceTimeoutsConfKey, serviceName, err)
+			}
+			wrapper.serviceTimeouts[serviceName] = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return nil
 }
 
 type customRoundTripper struct {
@@ -101,7 +253,7 @@ func uniteInterceptors(interceptors []client.HTTPClientInterceptor) client.HTTPC
 	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
 		tailHandler := func(innerReq *http.Request) (*http.Response, error) {
 			unitedInterceptor := uniteInterceptors(interceptors[1:])
-			return unitedInterceptor(req, handler)
+			return unitedInterceptor(innerReq, handler)
 		}
 		headInterceptor := interceptors[0]
 		return headInterceptor(req, tailHandler)
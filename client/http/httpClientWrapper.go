@@ -4,17 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/orchestd/dependencybundler/interfaces/configuration"
 	. "github.com/orchestd/servicereply"
 	"github.com/orchestd/servicereply/status"
 	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/client/http/balancers"
 	"github.com/orchestd/transport/discoveryService"
+	"github.com/orchestd/transport/replyCodec"
+	"github.com/orchestd/transport/replyTypes"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
+	"time"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -28,6 +35,12 @@ type httpClientWrapper struct {
 	client                   *http.Client
 	conf                     configuration.Config
 	discoveryServiceProvider discoveryService.DiscoveryServiceProvider
+	replyContentType         string
+	balancer                 client.Balancer
+	serviceBalancers         map[string]client.Balancer
+	timeout                  time.Duration
+	serviceTimeouts          map[string]time.Duration
+	resolveHooks             []client.ResolveFailureHook
 }
 
 func (h *httpClientWrapper) Call(c context.Context, payload interface{}, host, handler string, target interface{}, headers map[string]string) ServiceReply {
@@ -73,10 +86,39 @@ func (h *httpClientWrapper) SetDiscoveryServiceProvider(dsp discoveryService.Dis
 }
 
 func NewHttpClientWrapper(client *http.Client, conf configuration.Config) (client.HttpClient, error) {
-	return &httpClientWrapper{client: client, conf: conf}, nil
+	return &httpClientWrapper{client: client, conf: conf, balancer: balancers.RoundRobin()}, nil
+}
+
+// withTimeout bounds the call with the timeout of its service, or the one given by client.WithCallTimeout
+func (h *httpClientWrapper) withTimeout(c context.Context, host string) (context.Context, context.CancelFunc) {
+	timeout, ok := client.CallTimeoutFromContext(c)
+	if !ok {
+		if timeout, ok = h.serviceTimeouts[host]; !ok {
+			timeout = h.timeout
+		}
+	}
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	return context.WithTimeout(c, timeout)
+}
+
+// sendError maps the error of a call to its reply, timeouts get the replyTypes.TimeoutReplyType
+func sendError(err error, logMessage string) ServiceReply {
+	var replyErr client.ReplyError
+	if errors.As(err, &replyErr) {
+		return replyErr.ServiceReply()
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return replyTypes.NewTimeoutError(err).WithLogMessage(logMessage)
+	}
+	return NewIoError(err).WithLogMessage(logMessage)
 }
 
 func (h *httpClientWrapper) doPostForm(c context.Context, uri string, postData, headers map[string]string) ([]byte, ServiceReply) {
+	c, cancel := h.withTimeout(c, "")
+	defer cancel()
 	data := url.Values{}
 	for k, v := range postData {
 		data.Set(k, v)
@@ -97,19 +139,107 @@ func (h *httpClientWrapper) doPostForm(c context.Context, uri string, postData,
 
 	resp, err := h.client.Do(request)
 	if err != nil {
-		return nil, NewIoError(err).WithLogMessage(fmt.Sprintf("couldn't send request"))
+		return nil, sendError(err, "couldn't send request")
 	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, NewIoError(err).WithLogMessage(fmt.Sprintf("cannot read response"))
+		return nil, sendError(err, "cannot read response")
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, NewIoError(err).WithLogMessage(fmt.Sprintf("got response status code %s", resp.StatusCode))
+		return nil, NewIoError(err).WithLogMessage(fmt.Sprintf("got response status code %d", resp.StatusCode))
 	}
 	return body, nil
 }
 
+// resolve returns the address of the instance of host the call is sent to, done must be called once the call
+// completed
+func (h *httpClientWrapper) resolve(host string) (string, func(), ServiceReply) {
+	if resolver, ok := h.discoveryServiceProvider.(discoveryService.Resolver); ok {
+		instances, sRep := resolver.Resolve(host)
+		if sRep != nil && !sRep.IsSuccess() {
+			return "", nil, sRep
+		}
+		if len(instances) == 0 {
+			return "", nil, NewNetworkError(fmt.Errorf("cant resolve host:%s (no healthy instances)", host))
+		}
+		address, done := h.pick(host, instances)
+		return address, done, nil
+	}
+	if sRep := h.discoveryServiceProvider.GetAddress(host); !sRep.IsSuccess() {
+		return "", nil, sRep
+	} else if v, ok := sRep.GetReplyValues()["address"]; !ok {
+		return "", nil, sRep.WithError(fmt.Errorf("cant resolve host:%s", host))
+	} else if v == host || v == "" {
+		return "", nil, sRep.WithError(fmt.Errorf("cant resolve host:%s (need to define env or discovery service)", host))
+	} else {
+		return fmt.Sprint(v), func() {}, nil
+	}
+}
+
+// pick balances the call between the instances of host, done is never nil
+func (h *httpClientWrapper) pick(host string, instances []discoveryService.Instance) (string, func()) {
+	instance, done := h.balancerFor(host).Pick(host, instances)
+	if done == nil {
+		done = func() {}
+	}
+	return instance.Address, done
+}
+
+// resolver picks other instances of host for the interceptors sending the call again, the done funcs of the
+// instances it picks are added to dones
+func (h *httpClientWrapper) resolver(host string, dones *[]func()) client.Resolver {
+	var mu sync.Mutex
+	return func(exclude ...string) (string, bool) {
+		resolver, ok := h.discoveryServiceProvider.(discoveryService.Resolver)
+		if !ok {
+			return "", false
+		}
+		instances, sRep := resolver.Resolve(host)
+		if sRep != nil && !sRep.IsSuccess() {
+			return "", false
+		}
+		excluded := make(map[string]bool, len(exclude))
+		for _, address := range exclude {
+			excluded[baseAddress(address)] = true
+		}
+		candidates := make([]discoveryService.Instance, 0, len(instances))
+		for _, instance := range instances {
+			if !excluded[baseAddress(instance.Address)] {
+				candidates = append(candidates, instance)
+			}
+		}
+		if len(candidates) == 0 {
+			// every instance failed, the balancer picks between all of them again
+			candidates = instances
+		}
+		if len(candidates) == 0 {
+			return "", false
+		}
+		address, done := h.pick(host, candidates)
+		mu.Lock()
+		defer mu.Unlock()
+		*dones = append(*dones, done)
+		return address, true
+	}
+}
+
+// baseAddress returns the scheme and host of an address, so addresses compare whatever their path
+func baseAddress(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return address
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (h *httpClientWrapper) balancerFor(host string) client.Balancer {
+	if b, ok := h.serviceBalancers[host]; ok {
+		return b
+	}
+	return h.balancer
+}
+
 func (h *httpClientWrapper) do(c context.Context, httpMethod string, payload interface{}, host, handler string,
 	target interface{}, headers map[string]string, internal bool) (srvReply ServiceReply) {
 	return h.doFull(c, httpMethod, payload, host, handler, target, headers, internal, ContentTypeJSON)
@@ -118,16 +248,24 @@ func (h *httpClientWrapper) do(c context.Context, httpMethod string, payload int
 func (h *httpClientWrapper) doFull(c context.Context, httpMethod string, payload interface{}, host, handler string,
 	target interface{}, headers map[string]string, internal bool, contentType string) (srvReply ServiceReply) {
 	var url string
-	if sRep := h.discoveryServiceProvider.GetAddress(host); !sRep.IsSuccess() {
+	var dones []func()
+	if address, done, sRep := h.resolve(host); sRep != nil {
+		for _, hook := range h.resolveHooks {
+			hook(host, sRep)
+		}
 		return sRep
-	} else if v, ok := sRep.GetReplyValues()["address"]; !ok {
-		return sRep.WithError(fmt.Errorf("cant resolve host:%s", host))
-	} else if v == host || v == "" {
-		return sRep.WithError(fmt.Errorf("cant resolve host:%s (need to define env or discovery service)", host))
 	} else {
-		url = fmt.Sprintf("%s/%s", v, handler)
+		dones = append(dones, done)
+		defer func() {
+			for _, done := range dones {
+				done()
+			}
+		}()
+		url = fmt.Sprintf("%s/%s", address, handler)
 	}
 
+	c, cancel := h.withTimeout(c, host)
+	defer cancel()
 	srvReply = NewNil()
 	b, sErr := getPayload(payload, url)
 	if sErr != nil {
@@ -155,10 +293,14 @@ func (h *httpClientWrapper) doFull(c context.Context, httpMethod string, payload
 		req, err = http.NewRequest(httpMethod, url, nil)
 	}
 
-	req = req.WithContext(c)
+	req = req.WithContext(client.WithResolver(client.WithCallTarget(c, client.CallTarget{Service: host, Handler: handler}),
+		h.resolver(host, &dones)))
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
+	if _, ok := headers["Accept"]; !ok && internal && h.replyContentType != "" {
+		req.Header.Set("Accept", h.replyContentType)
+	}
 	if _, ok := headers["Content-Type"]; !ok {
 		if contentType == ContentTypeJSON {
 			req.Header.Add("Content-Type", "application/json")
@@ -169,17 +311,20 @@ func (h *httpClientWrapper) doFull(c context.Context, httpMethod string, payload
 
 	resp, err := h.client.Do(req)
 	if err != nil {
-		return NewIoError(err).WithLogMessage(fmt.Sprintf("couldn't send %s request to %s", httpMethod, url))
+		return sendError(err, fmt.Sprintf("couldn't send %s request to %s", httpMethod, url))
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		if c.Err() == context.DeadlineExceeded {
+			return replyTypes.NewTimeoutError(err).WithLogMessage(fmt.Sprintf("cannot read response from %s", url))
+		}
 		return NewInternalServiceError(err).WithLogMessage(fmt.Sprintf("cannot read response from %s", url))
 	}
 	if internal {
 		var srvError Response
-		if err := json.Unmarshal(body, &srvError); err != nil {
+		if err := replyCodec.ForContentType(resp.Header.Get("Content-Type")).Unmarshal(body, &srvError); err != nil {
 			return NewInternalServiceError(err).WithLogMessage(fmt.Sprintf("cannot read response from %s", url)).WithLogValues(ValuesMap{"rawResponse": string(body)})
 		}
 		if srvError.Status != status.SuccessStatus {
@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/configurations/config/confgetter"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/discoveryService"
+	"github.com/orchestd/transport/replyTypes"
+	"github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	nethttp "net/http"
+	"testing"
+	"time"
+)
+
+type SleepReq struct {
+	Ms int `json:"ms"`
+}
+
+func Test_Timeouts(t *testing.T) {
+	routes := func(router gin.IRouter) {
+		router.POST("/sleep", http.Handle(func(c context.Context, req SleepReq) (interface{}, servicereply.ServiceReply) {
+			time.Sleep(time.Duration(req.Ms) * time.Millisecond)
+			return nil, nil
+		}))
+	}
+	cluster := transportTest.NewCluster(t, transportTest.Service{Name: "users", Routes: routes},
+		transportTest.Service{Name: "orders", Routes: routes})
+
+	convey.Convey("Given a client without timeouts", t, func() {
+		var deadlines []bool
+		httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+			AddInterceptors(func(req *nethttp.Request, next client.HTTPHandler) (*nethttp.Response, error) {
+				_, ok := req.Context().Deadline()
+				deadlines = append(deadlines, ok)
+				return next(req)
+			}))
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.So(httpClient.Call(context.Background(), SleepReq{}, "users", "sleep", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+		convey.So(deadlines, convey.ShouldResemble, []bool{false})
+	})
+
+	convey.Convey("Given a client with a default timeout and a service override", t, func() {
+		httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+			SetTimeout(50*time.Millisecond).SetServiceTimeout("orders", time.Second))
+		convey.So(err, convey.ShouldBeNil)
+
+		sRep := httpClient.Call(context.Background(), SleepReq{Ms: 200}, "users", "sleep", nil, nil)
+		convey.So(replyTypes.Is(sRep, replyTypes.TimeoutReplyType), convey.ShouldBeTrue)
+		convey.So(replyTypes.GetHttpCode(sRep.GetErrorType()), convey.ShouldEqual, 504)
+		convey.So(httpClient.Call(context.Background(), SleepReq{Ms: 200}, "orders", "sleep", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+
+		convey.Convey("per call timeouts override them", func() {
+			ctx := client.WithCallTimeout(context.Background(), time.Second)
+			convey.So(httpClient.Call(ctx, SleepReq{Ms: 200}, "users", "sleep", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+			ctx = client.WithCallTimeout(context.Background(), 50*time.Millisecond)
+			sRep := httpClient.Call(ctx, SleepReq{Ms: 200}, "orders", "sleep", nil, nil)
+			convey.So(replyTypes.Is(sRep, replyTypes.TimeoutReplyType), convey.ShouldBeTrue)
+		})
+	})
+
+	convey.Convey("Given timeouts in the configuration", t, func() {
+		conf := confgetter.NewConfgetterWrapper(map[string]interface{}{
+			"clienttimeoutms":         "1000",
+			"clientservicetimeoutsms": map[string]interface{}{"orders": "50"},
+		})
+		httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(&conf).
+			SetTimeout(50*time.Millisecond).SetServiceTimeout("orders", time.Second))
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.So(httpClient.Call(context.Background(), SleepReq{Ms: 200}, "users", "sleep", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+		sRep := httpClient.Call(context.Background(), SleepReq{Ms: 200}, "orders", "sleep", nil, nil)
+		convey.So(replyTypes.Is(sRep, replyTypes.TimeoutReplyType), convey.ShouldBeTrue)
+	})
+}
+
+// firstInstance picks the first instance without tracking its calls
+type firstInstance struct{}
+
+func (firstInstance) Pick(_ string, instances []discoveryService.Instance) (discoveryService.Instance, func()) {
+	return instances[0], nil
+}
+
+func Test_Balancer(t *testing.T) {
+	cluster := transportTest.NewCluster(t, transportTest.Service{Name: "users", Routes: func(router gin.IRouter) {
+		router.POST("/sleep", http.Handle(func(c context.Context, req SleepReq) (interface{}, servicereply.ServiceReply) {
+			return nil, nil
+		}))
+	}})
+
+	convey.Convey("Given a balancer without a done func", t, func() {
+		httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+			SetBalancer(firstInstance{}))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(httpClient.Call(context.Background(), SleepReq{}, "users", "sleep", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+	})
+}
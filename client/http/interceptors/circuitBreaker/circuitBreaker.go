@@ -0,0 +1,219 @@
+package circuitBreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/replyTypes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureRatio   = 0.5
+	defaultMinRequests    = 10
+	defaultWindow         = 30 * time.Second
+	defaultOpenTimeout    = 10 * time.Second
+	defaultHalfOpenProbes = 1
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+// ErrOpen is matched by the errors returned while a circuit is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// OpenError is returned instead of calling a host whose circuit is open, the http client replies with a
+// replyTypes.CircuitOpenReplyType error
+type OpenError struct {
+	Host string
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open", e.Host)
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+func (e *OpenError) ServiceReply() servicereply.ServiceReply {
+	return replyTypes.NewCircuitOpenError(e).WithReplyValues(servicereply.ValuesMap{"host": e.Host})
+}
+
+// Settings configures the breakers, zero values get the defaults
+type Settings struct {
+	// FailureRatio of the calls in a window opens the circuit, 0.5 by default
+	FailureRatio float64
+	// MinRequests is the number of calls in a window before the ratio is checked, 10 by default
+	MinRequests int
+	// Window is how long calls are counted before the counts are reset, 30s by default
+	Window time.Duration
+	// OpenTimeout is how long the circuit stays open before probe calls are let through, 10s by default
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of probe calls let through at once while half-open, the circuit closes once
+	// that many succeeded. 1 by default.
+	HalfOpenProbes int
+	// IsFailure tells whether a call failed, transport errors and 5xx responses by default. Calls whose context
+	// ended are never counted.
+	IsFailure func(res *http.Response, err error) bool
+	// Logger reports the state changes when set
+	Logger log.Logger
+}
+
+// CircuitBreaker keeps a breaker per resolved host. A breaker opens when the failure ratio of a window is
+// reached, calls to its host then fail immediately with an OpenError. After OpenTimeout probe calls are let
+// through, the breaker closes when they succeed and opens again when one fails.
+func CircuitBreaker(settings Settings) client.HTTPClientInterceptor {
+	if settings.FailureRatio <= 0 {
+		settings.FailureRatio = defaultFailureRatio
+	}
+	if settings.MinRequests <= 0 {
+		settings.MinRequests = defaultMinRequests
+	}
+	if settings.Window <= 0 {
+		settings.Window = defaultWindow
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = defaultOpenTimeout
+	}
+	if settings.HalfOpenProbes <= 0 {
+		settings.HalfOpenProbes = defaultHalfOpenProbes
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = isFailure
+	}
+	var mu sync.Mutex
+	breakers := map[string]*breaker{}
+	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+		host := req.URL.Host
+		mu.Lock()
+		b, ok := breakers[host]
+		if !ok {
+			b = &breaker{host: host, settings: &settings, state: StateClosed}
+			breakers[host] = b
+		}
+		mu.Unlock()
+
+		generation, allowed := b.allow(req.Context())
+		if !allowed {
+			return nil, &OpenError{Host: host}
+		}
+		res, err := handler(req)
+		if err != nil && req.Context().Err() != nil {
+			// the caller canceled the call or its deadline passed, the host didn't fail
+			b.release(generation)
+			return res, err
+		}
+		b.done(req.Context(), generation, settings.IsFailure(res, err))
+		return res, err
+	}
+}
+
+func isFailure(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+type breaker struct {
+	host     string
+	settings *Settings
+
+	mu          sync.Mutex
+	state       State
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// allow tells whether a call may be sent, along with the generation of the state it was sent in so results of
+// calls sent before a state change are ignored
+func (b *breaker) allow(ctx context.Context) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.settings.OpenTimeout {
+			return 0, false
+		}
+		b.setState(ctx, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			return 0, false
+		}
+		b.probes++
+	default:
+		if now.Sub(b.windowStart) >= b.settings.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	}
+	return b.generation, true
+}
+
+func (b *breaker) done(ctx context.Context, generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+	switch b.state {
+	case StateHalfOpen:
+		b.probes--
+		if failed {
+			b.setState(ctx, StateOpen)
+		} else if b.successes++; b.successes >= b.settings.HalfOpenProbes {
+			b.setState(ctx, StateClosed)
+		}
+	case StateClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.settings.MinRequests && float64(b.failures)/float64(b.requests) >= b.settings.FailureRatio {
+			b.setState(ctx, StateOpen)
+		}
+	}
+}
+
+// release frees the probe of a call that isn't counted
+func (b *breaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == StateHalfOpen {
+		b.probes--
+	}
+}
+
+// setState resets the counts of the new state, it is called with the lock held
+func (b *breaker) setState(ctx context.Context, state State) {
+	from := b.state
+	b.state = state
+	b.generation++
+	b.requests, b.failures, b.probes, b.successes = 0, 0, 0, 0
+	b.windowStart = time.Now()
+	if state == StateOpen {
+		b.openedAt = time.Now()
+	}
+	if b.settings.Logger == nil {
+		return
+	}
+	if state == StateOpen {
+		b.settings.Logger.Warn(ctx, "Circuit breaker for %s changed from %s to %s", b.host, from, state)
+	} else {
+		b.settings.Logger.Info(ctx, "Circuit breaker for %s changed from %s to %s", b.host, from, state)
+	}
+}
@@ -0,0 +1,91 @@
+package circuitBreaker
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	logDep "github.com/orchestd/log"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/replyTypes"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	var failing int32 = 1
+	var calls, slowCalls int32
+	cluster := transportTest.NewCluster(t, transportTest.Service{Name: "users", Routes: func(router gin.IRouter) {
+		router.GET("/user", func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{})
+		})
+	}}, transportTest.Service{Name: "orders", Routes: func(router gin.IRouter) {
+		router.GET("/slow", func(c *gin.Context) {
+			atomic.AddInt32(&slowCalls, 1)
+			time.Sleep(100 * time.Millisecond)
+			c.JSON(http.StatusOK, gin.H{})
+		})
+	}})
+	logger := transportTest.NewLogger()
+	httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).AddInterceptors(CircuitBreaker(Settings{
+		MinRequests: 4,
+		OpenTimeout: 50 * time.Millisecond,
+		Logger:      logger,
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convey.Convey("Given calls whose context ended", t, func() {
+		for i := 0; i < 4; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			httpClient.Get(ctx, "orders", "slow", nil, nil)
+			cancel()
+		}
+
+		convey.Convey("they are not counted as failures", func() {
+			convey.So(httpClient.Get(context.Background(), "orders", "slow", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&slowCalls), convey.ShouldEqual, 5)
+			convey.So(logger.Find(logDep.WarnLevel), convey.ShouldBeEmpty)
+		})
+	})
+
+	convey.Convey("Given a service failing every call", t, func() {
+		for i := 0; i < 4; i++ {
+			httpClient.Get(context.Background(), "users", "user", nil, nil)
+		}
+		convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 4)
+
+		convey.Convey("the circuit opens and calls fail without reaching it", func() {
+			sRep := httpClient.Get(context.Background(), "users", "user", nil, nil)
+			convey.So(replyTypes.Is(sRep, replyTypes.CircuitOpenReplyType), convey.ShouldBeTrue)
+			convey.So(replyTypes.GetHttpCode(sRep.GetErrorType()), convey.ShouldEqual, http.StatusServiceUnavailable)
+			convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 4)
+			convey.So(logger.Find(logDep.WarnLevel), convey.ShouldHaveLength, 1)
+
+			convey.Convey("a failed probe opens it again", func() {
+				time.Sleep(60 * time.Millisecond)
+				httpClient.Get(context.Background(), "users", "user", nil, nil)
+				convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 5)
+				sRep := httpClient.Get(context.Background(), "users", "user", nil, nil)
+				convey.So(replyTypes.Is(sRep, replyTypes.CircuitOpenReplyType), convey.ShouldBeTrue)
+
+				convey.Convey("a successful probe closes it", func() {
+					atomic.StoreInt32(&failing, 0)
+					time.Sleep(60 * time.Millisecond)
+					convey.So(httpClient.Get(context.Background(), "users", "user", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+					convey.So(httpClient.Get(context.Background(), "users", "user", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+					convey.So(atomic.LoadInt32(&calls), convey.ShouldEqual, 7)
+					convey.So(logger.Entries()[len(logger.Entries())-1].Message, convey.ShouldEndWith, "from half-open to closed")
+				})
+			})
+		})
+	})
+}
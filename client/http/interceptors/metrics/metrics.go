@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/orchestd/transport/client"
+	transportMetrics "github.com/orchestd/transport/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Metrics observes the duration of every call and the calls in flight, labelled with the name of the target
+// service rather than the address it was resolved to. Calls made without a target, e.g. PostForm, are labelled
+// with the host of their url.
+func Metrics(m *transportMetrics.Metrics) client.HTTPClientInterceptor {
+	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+		service := req.URL.Host
+		if target, ok := client.CallTargetFromContext(req.Context()); ok {
+			service = target.Service
+		}
+		inFlight := m.ClientInFlight.WithLabelValues(service)
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+
+		res, err := handler(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(res.StatusCode)
+		}
+		m.ClientDuration.WithLabelValues(service, req.Method, code).Observe(time.Since(start).Seconds())
+		return res, err
+	}
+}
@@ -0,0 +1,17 @@
+package requestId
+
+import (
+	"github.com/orchestd/transport/client"
+	transportRequestId "github.com/orchestd/transport/requestId"
+	"net/http"
+)
+
+// RequestId forwards the request id of the call context in the X-Request-ID header, unless the call sets one
+func RequestId() client.HTTPClientInterceptor {
+	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+		if id, ok := transportRequestId.FromContext(req.Context()); ok && req.Header.Get(transportRequestId.Header) == "" {
+			req.Header.Set(transportRequestId.Header, id)
+		}
+		return handler(req)
+	}
+}
@@ -0,0 +1,248 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/replyTypes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// NoJitter is the Policy Jitter waiting exactly the backoff
+	NoJitter = -1
+
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 2 * time.Second
+	defaultMultiplier     = 2
+	defaultJitter         = 0.2
+	defaultMaxRetryAfter  = 10 * time.Second
+)
+
+var defaultRetryableStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout}
+
+// Policy configures how a call is retried, zero values get the defaults
+type Policy struct {
+	// MaxAttempts counts the first attempt, 3 by default and 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, 100ms by default
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, 2s by default
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after every attempt, 2 by default
+	Multiplier float64
+	// Jitter is the fraction of the backoff randomly added or removed, 0.2 by default and NoJitter disables it
+	Jitter float64
+	// RetryableStatusCodes are the response codes that are retried, 429, 502, 503 and 504 by default. Transport
+	// errors are always retried, except the errors of an open circuit breaker.
+	RetryableStatusCodes []int
+	// MaxRetryAfter is the longest Retry-After that is waited for, the response is returned when it asks for more.
+	// 10s by default.
+	MaxRetryAfter time.Duration
+}
+
+// Settings holds the policies of the interceptor, the policy of a call is looked up by its handler, then by its
+// service and falls back to Default
+type Settings struct {
+	Default Policy
+	// Services are keyed by the service name the call was resolved from
+	Services map[string]Policy
+	// Handlers are keyed by service and handler, e.g. "users/getUser"
+	Handlers map[string]Policy
+}
+
+// Retry retries the calls that failed on a transport error or a retryable status code. Only idempotent methods
+// and requests carrying an Idempotency-Key header are retried, and only when their body can be sent again. The
+// backoff grows exponentially with jitter, a Retry-After header replaces it, and no attempt is made when the
+// context deadline would pass before it. Every retry is sent to another instance of the service when there is one.
+func Retry(settings Settings) client.HTTPClientInterceptor {
+	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+		policy := settings.policy(req.Context()).withDefaults()
+		if policy.MaxAttempts <= 1 || !retryable(req) {
+			return handler(req)
+		}
+		backoff := policy.InitialBackoff
+		attempt := req
+		var failed []string
+		for i := 1; ; i++ {
+			res, err := handler(attempt)
+			if i >= policy.MaxAttempts || !policy.shouldRetry(req.Context(), res, err) {
+				return res, err
+			}
+			wait := jitter(backoff, policy.Jitter)
+			if retryAfter, ok := parseRetryAfter(res); ok {
+				if retryAfter > policy.MaxRetryAfter {
+					return res, err
+				}
+				wait = retryAfter
+			}
+			if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(wait).After(deadline) {
+				return res, err
+			}
+			next, rewindErr := rewind(req)
+			if rewindErr != nil {
+				return res, err
+			}
+			if res != nil {
+				// drain the body so the connection can be reused
+				io.Copy(ioutil.Discard, res.Body)
+				res.Body.Close()
+			}
+			if !sleep(req.Context(), wait) {
+				return nil, req.Context().Err()
+			}
+			failed = append(failed, attempt.URL.Scheme+"://"+attempt.URL.Host)
+			reresolve(next, failed)
+			attempt = next
+			backoff = time.Duration(float64(backoff) * policy.Multiplier)
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
+
+func (s Settings) policy(ctx context.Context) Policy {
+	if target, ok := client.CallTargetFromContext(ctx); ok {
+		handler := strings.SplitN(target.Handler, "?", 2)[0]
+		if policy, ok := s.Handlers[target.Service+"/"+handler]; ok {
+			return policy
+		}
+		if policy, ok := s.Services[target.Service]; ok {
+			return policy
+		}
+	}
+	return s.Default
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultMultiplier
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaultJitter
+	} else if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.RetryableStatusCodes == nil {
+		p.RetryableStatusCodes = defaultRetryableStatusCodes
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = defaultMaxRetryAfter
+	}
+	return p
+}
+
+func (p Policy) shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// a circuit breaker that is open fails again until it lets calls through
+		var replyErr client.ReplyError
+		return !errors.As(err, &replyErr) || !replyTypes.Is(replyErr.ServiceReply(), replyTypes.CircuitOpenReplyType)
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if res.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// rewind returns a copy of req with a fresh body, the transport consumed the body of the previous attempt
+func rewind(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		next.Body = body
+	}
+	return next, nil
+}
+
+// reresolve sends req to another instance of its service than the failed ones, it stays on its instance when the
+// call wasn't resolved by the http client or there is no other instance
+func reresolve(req *http.Request, failed []string) {
+	resolver, ok := client.ResolverFromContext(req.Context())
+	if !ok {
+		return
+	}
+	address, ok := resolver(failed...)
+	if !ok {
+		return
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return
+	}
+	req.URL.Scheme, req.URL.Host, req.Host = u.Scheme, u.Host, u.Host
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an http date
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func jitter(backoff time.Duration, fraction float64) time.Duration {
+	return backoff + time.Duration((rand.Float64()*2-1)*fraction*float64(backoff))
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
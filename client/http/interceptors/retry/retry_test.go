@@ -0,0 +1,146 @@
+package retry
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/client/http/interceptors/circuitBreaker"
+	"github.com/orchestd/transport/replyTypes"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Retry(t *testing.T) {
+	var attempts int32
+	var bodies []string
+	var mu sync.Mutex
+	var failing string
+	hits := map[string]int{}
+	cluster := transportTest.NewCluster(t, transportTest.Service{Name: "users", Routes: func(router gin.IRouter) {
+		// every third attempt succeeds
+		flaky := func(c *gin.Context) {
+			body, _ := ioutil.ReadAll(c.Request.Body)
+			bodies = append(bodies, string(body))
+			if atomic.AddInt32(&attempts, 1)%3 != 0 {
+				c.Header("Retry-After", c.Query("retryAfter"))
+				c.Status(http.StatusServiceUnavailable)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		}
+		router.GET("/flaky", flaky)
+		router.PUT("/flaky", flaky)
+		router.POST("/flaky", flaky)
+		router.GET("/once", flaky)
+		router.GET("/later", flaky)
+		router.GET("/down", func(c *gin.Context) {
+			c.Status(http.StatusInternalServerError)
+		})
+	}}, transportTest.Service{Name: "orders", Instances: 2, Routes: func(router gin.IRouter) {
+		router.GET("/order", func(c *gin.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			hits[c.Request.Host]++
+			if "http://"+c.Request.Host == failing {
+				c.Status(http.StatusServiceUnavailable)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+	}})
+	failing = cluster.Addresses("orders")[0]
+	fast := Policy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Jitter: NoJitter}
+	httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).AddInterceptors(Retry(Settings{
+		Default:  fast,
+		Handlers: map[string]Policy{"users/once": {MaxAttempts: 1}, "users/later": {MaxAttempts: 2, Jitter: NoJitter}},
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convey.Convey("Given a handler failing twice before succeeding", t, func() {
+		atomic.StoreInt32(&attempts, 0)
+		bodies = nil
+
+		convey.Convey("idempotent calls are retried with their body", func() {
+			var res map[string]bool
+			sRep := httpClient.Put(context.Background(), map[string]string{"name": "a"}, "users", "flaky", &res, nil)
+			convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+			convey.So(res["ok"], convey.ShouldBeTrue)
+			convey.So(bodies, convey.ShouldResemble, []string{`{"name":"a"}`, `{"name":"a"}`, `{"name":"a"}`})
+		})
+
+		convey.Convey("non idempotent calls are retried only with an idempotency key", func() {
+			httpClient.Post(context.Background(), nil, "users", "flaky", nil, nil)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 1)
+
+			sRep := httpClient.Post(context.Background(), nil, "users", "flaky", nil, map[string]string{IdempotencyKeyHeader: "1"})
+			convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("Retry-After is waited for", func() {
+			start := time.Now()
+			httpClient.Get(context.Background(), "users", "later?retryAfter=1", nil, nil)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 2)
+			convey.So(time.Since(start), convey.ShouldBeGreaterThanOrEqualTo, time.Second)
+		})
+
+		convey.Convey("no attempt is made past the context deadline", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			httpClient.Get(ctx, "users", "flaky?retryAfter=1", nil, nil)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("handler policies override the default", func() {
+			httpClient.Get(context.Background(), "users", "once", nil, nil)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("handler policies are looked up without the query", func() {
+			httpClient.Get(context.Background(), "users", "once?retryAfter=0", nil, nil)
+			convey.So(atomic.LoadInt32(&attempts), convey.ShouldEqual, 1)
+		})
+	})
+
+	convey.Convey("Given a circuit breaker that opened", t, func() {
+		var sent int32
+		breakerClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).AddInterceptors(
+			Retry(Settings{Default: fast}), func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+				atomic.AddInt32(&sent, 1)
+				return handler(req)
+			}, circuitBreaker.CircuitBreaker(circuitBreaker.Settings{MinRequests: 1})))
+		convey.So(err, convey.ShouldBeNil)
+		breakerClient.Get(context.Background(), "users", "down", nil, nil)
+
+		convey.Convey("its errors are not retried", func() {
+			sRep := breakerClient.Get(context.Background(), "users", "down", nil, nil)
+			convey.So(replyTypes.Is(sRep, replyTypes.CircuitOpenReplyType), convey.ShouldBeTrue)
+			convey.So(atomic.LoadInt32(&sent), convey.ShouldEqual, 2)
+		})
+	})
+
+	convey.Convey("Given a service with a failing instance", t, func() {
+		for i := 0; i < 4; i++ {
+			convey.So(httpClient.Get(context.Background(), "orders", "order", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+		}
+
+		convey.Convey("retries are sent to the other instance", func() {
+			mu.Lock()
+			defer mu.Unlock()
+			failingHits := hits[strings.TrimPrefix(failing, "http://")]
+			convey.So(failingHits, convey.ShouldBeBetweenOrEqual, 1, 4)
+			convey.So(hits, convey.ShouldHaveLength, 2)
+			convey.So(hits[strings.TrimPrefix(cluster.Addresses("orders")[1], "http://")], convey.ShouldEqual, 4)
+		})
+	})
+}
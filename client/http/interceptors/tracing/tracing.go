@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"github.com/orchestd/transport/client"
+	transportTracing "github.com/orchestd/transport/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+// attributes of the client spans
+const (
+	TargetServiceKey = attribute.Key("orchestd.target.service")
+	// TargetHandlerKey is the handler as called, along with its query
+	TargetHandlerKey = attribute.Key("orchestd.target.handler")
+)
+
+// Tracing starts a client span for every call, as a child of the span of the request context, and sends its
+// traceparent and tracestate headers to the called service
+func Tracing(tp trace.TracerProvider) client.HTTPClientInterceptor {
+	tracer := tp.Tracer(transportTracing.InstrumentationName)
+	return func(req *http.Request, handler client.HTTPHandler) (*http.Response, error) {
+		name := req.Method + " " + req.URL.Host
+		attributes := semconv.HTTPClientAttributesFromHTTPRequest(req)
+		if target, ok := client.CallTargetFromContext(req.Context()); ok {
+			// the query is left out of the name so the names stay bounded
+			name = target.Service + "/" + strings.SplitN(target.Handler, "?", 2)[0]
+			attributes = append(attributes, TargetServiceKey.String(target.Service), TargetHandlerKey.String(target.Handler))
+		}
+		ctx, span := tracer.Start(req.Context(), name, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attributes...))
+		defer span.End()
+
+		req = req.WithContext(ctx)
+		transportTracing.Propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		res, err := handler(req)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return res, err
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(res.StatusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(res.StatusCode, trace.SpanKindClient))
+		return res, err
+	}
+}
@@ -0,0 +1,92 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/orchestd/configurations/config/confgetter"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/discoveryService"
+	"github.com/orchestd/transport/discoveryService/memoryDiscovery"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_TLS(t *testing.T) {
+	certs := transportTest.NewCertificates(t)
+	serverCert, err := tls.LoadX509KeyPair(certs.ServerCertFile, certs.ServerKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, _ := os.ReadFile(certs.CAFile)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caPEM)
+
+	var peer string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peer = ""
+		if len(r.TLS.PeerCertificates) > 0 {
+			peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		w.Write([]byte("{}"))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: clientCAs, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	defer srv.Close()
+
+	registry := memoryDiscovery.NewRegistry()
+	registry.Add("secure", discoveryService.Instance{Address: srv.URL})
+	emptyConf := confgetter.NewConfgetterWrapper(map[string]interface{}{})
+	conf := &emptyConf
+	newClient := func(builder client.HTTPClientBuilder) client.HttpClient {
+		httpClient, err := builder.SetConfig(conf).Build()
+		convey.So(err, convey.ShouldBeNil)
+		httpClient.SetDiscoveryServiceProvider(registry)
+		return httpClient
+	}
+	defaultTLS := http.DefaultTransport.(*http.Transport).TLSClientConfig
+
+	convey.Convey("Given a server with a certificate of an unknown authority", t, func() {
+		convey.Convey("certificates are verified by default", func() {
+			httpClient := newClient(clientHttp.HTTPClientBuilder())
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("the authority can be trusted", func() {
+			httpClient := newClient(clientHttp.HTTPClientBuilder().SetTLS(client.TLSSettings{CAFiles: []string{certs.CAFile}}))
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+			convey.So(peer, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("a client certificate is presented", func() {
+			httpClient := newClient(clientHttp.HTTPClientBuilder().SetTLS(client.TLSSettings{CAFiles: []string{certs.CAFile},
+				CertFile: certs.ClientCertFile, KeyFile: certs.ClientKeyFile, MinVersion: tls.VersionTLS13}))
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+			convey.So(peer, convey.ShouldEqual, transportTest.ClientCommonName)
+		})
+
+		convey.Convey("the server name is overridden", func() {
+			httpClient := newClient(clientHttp.HTTPClientBuilder().SetTLS(client.TLSSettings{CAFiles: []string{certs.CAFile},
+				ServerName: "example.com"}))
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("verification is disabled only for the insecure client", func() {
+			httpClient := newClient(clientHttp.HTTPClientBuilder().SetTLS(client.TLSSettings{InsecureSkipVerify: true}))
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+			convey.So(http.DefaultTransport.(*http.Transport).TLSClientConfig, convey.ShouldEqual, defaultTLS)
+			httpClient = newClient(clientHttp.HTTPClientBuilder())
+			convey.So(httpClient.Get(context.Background(), "secure", "", nil, nil).IsSuccess(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("missing files fail the build", func() {
+			_, err := clientHttp.HTTPClientBuilder().SetConfig(conf).SetTLS(client.TLSSettings{CAFiles: []string{"missing.pem"}}).Build()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
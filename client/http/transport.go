@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/orchestd/transport/client"
+	"golang.org/x/net/http2"
+	"net"
+	"net/http"
+)
+
+// configureTransport applies the settings to the cloned transport of a client
+func configureTransport(t *http.Transport, settings client.TransportSettings) (http.RoundTripper, error) {
+	if settings.H2C && (settings.MaxIdleConnsPerHost > 0 || settings.MaxConnsPerHost > 0) {
+		return nil, fmt.Errorf("MaxIdleConnsPerHost and MaxConnsPerHost don't apply to h2c connections")
+	}
+	if settings.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+	if settings.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = settings.MaxConnsPerHost
+	}
+	if settings.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = settings.IdleConnTimeout
+	}
+	if !settings.HTTP2 && !settings.H2C {
+		return t, nil
+	}
+	// the clone may carry the HTTP/2 registration of the transport it was cloned from
+	t.TLSNextProto = nil
+	t2, err := http2.ConfigureTransports(t)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure HTTP/2: %w", err)
+	}
+	t2.StrictMaxConcurrentStreams = settings.StrictMaxConcurrentStreams
+	if !settings.H2C {
+		return t, nil
+	}
+	// the HTTP/2 transport registered on t only reuses connections t dialed over TLS, cleartext calls need their own.
+	// It is registered on a clone of t so it reads the idle timeout and the other timeouts of t, and gets a pool
+	// dialing its connections instead of the one only reusing those of t.
+	h2cBase := t.Clone()
+	// the bundled HTTP/2 would be registered on the clone before ours otherwise
+	h2cBase.TLSNextProto, h2cBase.ForceAttemptHTTP2 = nil, false
+	h2c, err := http2.ConfigureTransports(h2cBase)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure h2c: %w", err)
+	}
+	h2c.ConnPool = nil
+	h2c.AllowHTTP = true
+	h2c.StrictMaxConcurrentStreams = settings.StrictMaxConcurrentStreams
+	h2c.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		if t.DialContext != nil {
+			return t.DialContext(ctx, network, addr)
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	return &h2cTransport{https: t, h2c: h2c}, nil
+}
+
+// h2cTransport sends http:// calls over cleartext HTTP/2 and the others through the regular transport, which
+// negotiates HTTP/2 with TLS servers
+type h2cTransport struct {
+	https *http.Transport
+	h2c   *http2.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.https.RoundTrip(req)
+}
@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/server"
+	"github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	nethttp "net/http"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+type ProtoRes struct {
+	Proto string `json:"proto"`
+}
+
+func Test_Transport(t *testing.T) {
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name: "users",
+		Builder: func() server.HttpBuilder {
+			return http.Builder().SetHTTP2(server.HTTP2Settings{H2C: true, MaxConcurrentStreams: 10})
+		},
+		Routes: func(router gin.IRouter) {
+			router.POST("/proto", func(c *gin.Context) {
+				http.GinSuccessReply(c, ProtoRes{Proto: c.Request.Proto})
+			})
+		},
+	})
+	proto := func(httpClient client.HttpClient) string {
+		var res ProtoRes
+		sRep := httpClient.Call(context.Background(), nil, "users", "proto", &res, nil)
+		convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+		return res.Proto
+	}
+
+	convey.Convey("Given a server accepting h2c", t, func() {
+		convey.Convey("HTTP/1.1 clients are still served", func() {
+			convey.So(proto(cluster.Client()), convey.ShouldEqual, "HTTP/1.1")
+		})
+
+		convey.Convey("h2c clients call it over HTTP/2", func() {
+			httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+				SetTransport(client.TransportSettings{H2C: true, StrictMaxConcurrentStreams: true, IdleConnTimeout: time.Minute}))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(proto(httpClient), convey.ShouldEqual, "HTTP/2.0")
+		})
+
+		convey.Convey("h2c connections are closed once idle", func() {
+			httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+				SetTransport(client.TransportSettings{H2C: true, IdleConnTimeout: 100 * time.Millisecond}))
+			convey.So(err, convey.ShouldBeNil)
+			reused := func() bool {
+				var reused bool
+				ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+					reused = info.Reused
+				}})
+				convey.So(httpClient.Call(ctx, nil, "users", "proto", nil, nil).IsSuccess(), convey.ShouldBeTrue)
+				return reused
+			}
+			reused()
+			convey.So(reused(), convey.ShouldBeTrue)
+			time.Sleep(300 * time.Millisecond)
+			convey.So(reused(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("h2c clients can't limit the connections per host", func() {
+			_, err := clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+				SetTransport(client.TransportSettings{H2C: true, MaxConnsPerHost: 10}).Build()
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+
+	convey.Convey("Given a client tuning a transport it can't configure", t, func() {
+		_, err := clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+			WithPreconfiguredClient(&nethttp.Client{Transport: roundTripperFunc(func(*nethttp.Request) (*nethttp.Response, error) { return nil, nil })}).
+			SetTransport(client.TransportSettings{MaxIdleConnsPerHost: 10}).Build()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+type roundTripperFunc func(*nethttp.Request) (*nethttp.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	return f(req)
+}
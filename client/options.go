@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+type callTimeoutKey struct{}
+
+// WithCallTimeout returns a context making the calls sent with it time out after d, it overrides the timeouts set
+// on the client. A deadline already set on ctx still applies.
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, callTimeoutKey{}, d)
+}
+
+// CallTimeoutFromContext returns the timeout set with WithCallTimeout
+func CallTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(callTimeoutKey{}).(time.Duration)
+	return d, ok
+}
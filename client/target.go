@@ -0,0 +1,44 @@
+package client
+
+import "context"
+
+type callTargetKey struct{}
+
+// CallTarget is the logical destination of a call, interceptors only see the address of the resolved instance in
+// the request url
+type CallTarget struct {
+	// Service is the name the call was resolved from, e.g. "users"
+	Service string
+	// Handler is the handler path of the call, e.g. "getUser"
+	Handler string
+}
+
+// WithCallTarget returns a context carrying the target of the call, the http client sets it on every call made to
+// a service
+func WithCallTarget(ctx context.Context, target CallTarget) context.Context {
+	return context.WithValue(ctx, callTargetKey{}, target)
+}
+
+// CallTargetFromContext returns the target of the call a request belongs to
+func CallTargetFromContext(ctx context.Context) (CallTarget, bool) {
+	target, ok := ctx.Value(callTargetKey{}).(CallTarget)
+	return target, ok
+}
+
+type resolverKey struct{}
+
+// Resolver picks another instance of the target of a call, excluding the addresses given, e.g. "http://10.0.0.1:8080".
+// ok is false when the instance cannot be picked again.
+type Resolver func(exclude ...string) (address string, ok bool)
+
+// WithResolver returns a context carrying the resolver of the call, the http client sets it on every call made to a
+// service so interceptors sending a call again, like retries, can move away from an instance that failed
+func WithResolver(ctx context.Context, resolver Resolver) context.Context {
+	return context.WithValue(ctx, resolverKey{}, resolver)
+}
+
+// ResolverFromContext returns the resolver of the call a request belongs to
+func ResolverFromContext(ctx context.Context) (Resolver, bool) {
+	resolver, ok := ctx.Value(resolverKey{}).(Resolver)
+	return resolver, ok
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSSettings configures the TLS of the calls of a client
+type TLSSettings struct {
+	// CAFiles are PEM bundles of the certificate authorities trusted on top of the system ones
+	CAFiles []string
+	// CertFile and KeyFile are the PEM client certificate and key presented to servers requiring mTLS
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version, tls.VersionTLS12 by default
+	MinVersion uint16
+	// ServerName overrides the name the server certificates are verified against
+	ServerName string
+	// InsecureSkipVerify disables the verification of the server certificates, for this client only
+	InsecureSkipVerify bool
+}
+
+// Config builds the tls.Config of the settings
+func (s TLSSettings) Config() (*tls.Config, error) {
+	conf := &tls.Config{
+		MinVersion:         s.MinVersion,
+		ServerName:         s.ServerName,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+	if conf.MinVersion == 0 {
+		conf.MinVersion = tls.VersionTLS12
+	}
+	if len(s.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range s.CAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in CA bundle %s", file)
+			}
+		}
+		conf.RootCAs = pool
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
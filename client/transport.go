@@ -0,0 +1,21 @@
+package client
+
+import "time"
+
+// TransportSettings tunes the connections of a client
+type TransportSettings struct {
+	// HTTP2 negotiates HTTP/2 with TLS servers
+	HTTP2 bool
+	// H2C sends the calls to http:// addresses over HTTP/2 without TLS, the servers must accept h2c
+	H2C bool
+	// StrictMaxConcurrentStreams queues the calls over the max concurrent streams of a HTTP/2 connection instead
+	// of opening a new connection
+	StrictMaxConcurrentStreams bool
+	// MaxIdleConnsPerHost is the number of idle connections kept per instance, 2 by default. It can't be set
+	// with H2C.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per instance, no limit when zero. It can't be set with H2C.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes idle connections after that long, 90s by default
+	IdleConnTimeout time.Duration
+}
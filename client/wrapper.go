@@ -21,3 +21,10 @@ type HttpClient interface {
 type InternalClient interface {
 	Call(c context.Context, payload interface{}, host, handler string, target interface{}, headers map[string]string) servicereply.ServiceReply
 }
+
+// ReplyError is implemented by the errors of interceptors that decide the reply a call fails with, the http
+// client returns that reply instead of an io error
+type ReplyError interface {
+	error
+	ServiceReply() servicereply.ServiceReply
+}
@@ -6,6 +6,9 @@ type TransportConfiguration struct {
 	WriteTimeOutMs string   `json:"writeTimeOutMs,omitempty"`
 	ContextHeaders []string `json:"contextHeaders,omitempty"`
 
+	ClientTimeOutMs         string            `json:"clientTimeOutMs,omitempty"`
+	ClientServiceTimeOutsMs map[string]string `json:"clientServiceTimeOutsMs,omitempty"`
+
 	DiscoveryServiceProvider *string     `json:"discoveryServiceProvider"`
 	DspTemplate              *string     `json:"dspTemplate,omitempty"`
 	AssetRoots               interface{} `json:"assetRoots,omitempty"`
@@ -0,0 +1,320 @@
+package cachedDiscovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/discoveryService"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTTL         = 30 * time.Second
+	defaultNegativeTTL = 5 * time.Second
+	defaultMaxStale    = 5 * time.Minute
+)
+
+// Settings configures the cache, zero values get the defaults
+type Settings struct {
+	// TTL is how long resolved instances are served without asking the provider again, 30s by default
+	TTL time.Duration
+	// NegativeTTL is how long a failed resolution is served before the provider is asked again, 5s by default
+	NegativeTTL time.Duration
+	// MaxStale is how long after expiring instances are still served when the provider fails to resolve them,
+	// 5m by default and disabled when negative
+	MaxStale time.Duration
+	// RefreshInterval is how often entries used since they were resolved are refreshed in the background before
+	// they expire, TTL/2 by default and disabled when negative
+	RefreshInterval time.Duration
+}
+
+// Stats counts how resolutions were served
+type Stats struct {
+	// Hits were served from a fresh entry
+	Hits uint64
+	// Misses asked the provider
+	Misses uint64
+	// StaleHits were served from an expired entry because the provider failed
+	StaleHits uint64
+	// NegativeHits were served a cached failure
+	NegativeHits uint64
+	// Refreshes and RefreshErrors count the background refreshes
+	Refreshes     uint64
+	RefreshErrors uint64
+}
+
+// Provider caches the addresses resolved by another provider, registration calls are passed through. Close stops
+// the background refresh.
+type Provider interface {
+	discoveryService.DiscoveryServiceProvider
+	discoveryService.Resolver
+	discoveryService.Deregisterer
+	discoveryService.Heartbeater
+	// Invalidate drops the cached entry of a service, e.g. after its instances stopped answering
+	Invalidate(serviceName string)
+	// Stats are served on /metrics by metrics.Metrics.RegisterDiscoveryCache
+	Stats() Stats
+	Close()
+}
+
+// entry holds the last resolved instances of a service, they are fresh until expires. After a failure err is
+// served until errUntil, along with the instances while they aren't older than MaxStale.
+type entry struct {
+	instances []discoveryService.Instance
+	expires   time.Time
+	err       servicereply.ServiceReply
+	errUntil  time.Time
+	used      bool
+}
+
+type cachedProvider struct {
+	provider discoveryService.DiscoveryServiceProvider
+	settings Settings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inFlight map[string]*fetch
+
+	hits, misses, staleHits, negativeHits, refreshes, refreshErrors uint64
+
+	cancel context.CancelFunc
+}
+
+// fetch is a resolution in progress, concurrent callers wait for it instead of asking the provider again
+type fetch struct {
+	done      chan struct{}
+	instances []discoveryService.Instance
+	err       servicereply.ServiceReply
+}
+
+// NewCachedProvider wraps provider with a cache, its Resolve is used when it implements discoveryService.Resolver
+// and its GetAddress otherwise
+func NewCachedProvider(provider discoveryService.DiscoveryServiceProvider, settings Settings) Provider {
+	if settings.TTL <= 0 {
+		settings.TTL = defaultTTL
+	}
+	if settings.NegativeTTL <= 0 {
+		settings.NegativeTTL = defaultNegativeTTL
+	}
+	if settings.MaxStale == 0 {
+		settings.MaxStale = defaultMaxStale
+	} else if settings.MaxStale < 0 {
+		settings.MaxStale = 0
+	}
+	if settings.RefreshInterval == 0 {
+		settings.RefreshInterval = settings.TTL / 2
+	}
+	p := &cachedProvider{
+		provider: provider,
+		settings: settings,
+		now:      time.Now,
+		entries:  map[string]*entry{},
+		inFlight: map[string]*fetch{},
+	}
+	if settings.RefreshInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.refreshLoop(ctx)
+	}
+	return p
+}
+
+func (p *cachedProvider) Close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+func (p *cachedProvider) GetAddress(serviceName string) servicereply.ServiceReply {
+	instances, sRep := p.Resolve(serviceName)
+	if sRep != nil {
+		return sRep
+	}
+	return servicereply.NewNil().WithReplyValues(servicereply.ValuesMap{"address": instances[0].Address})
+}
+
+func (p *cachedProvider) Resolve(serviceName string) ([]discoveryService.Instance, servicereply.ServiceReply) {
+	p.mu.Lock()
+	e, ok := p.entries[serviceName]
+	now := p.now()
+	if ok && e.instances != nil && now.Before(e.expires) {
+		e.used = true
+		p.mu.Unlock()
+		atomic.AddUint64(&p.hits, 1)
+		return e.instances, nil
+	}
+	if ok && now.Before(e.errUntil) {
+		err := e.err
+		p.mu.Unlock()
+		if stale, ok := p.stale(serviceName); ok {
+			atomic.AddUint64(&p.staleHits, 1)
+			return stale, nil
+		}
+		atomic.AddUint64(&p.negativeHits, 1)
+		return nil, err
+	}
+	p.mu.Unlock()
+
+	atomic.AddUint64(&p.misses, 1)
+	instances, sRep := p.fetch(serviceName)
+	if sRep == nil {
+		return instances, nil
+	}
+	if stale, ok := p.stale(serviceName); ok {
+		atomic.AddUint64(&p.staleHits, 1)
+		return stale, nil
+	}
+	return nil, sRep
+}
+
+func (p *cachedProvider) Invalidate(serviceName string) {
+	p.mu.Lock()
+	delete(p.entries, serviceName)
+	p.mu.Unlock()
+}
+
+func (p *cachedProvider) Stats() Stats {
+	return Stats{
+		Hits:          atomic.LoadUint64(&p.hits),
+		Misses:        atomic.LoadUint64(&p.misses),
+		StaleHits:     atomic.LoadUint64(&p.staleHits),
+		NegativeHits:  atomic.LoadUint64(&p.negativeHits),
+		Refreshes:     atomic.LoadUint64(&p.refreshes),
+		RefreshErrors: atomic.LoadUint64(&p.refreshErrors),
+	}
+}
+
+func (p *cachedProvider) Register() servicereply.ServiceReply {
+	return p.provider.Register()
+}
+
+func (p *cachedProvider) Deregister() servicereply.ServiceReply {
+	if deregisterer, ok := p.provider.(discoveryService.Deregisterer); ok {
+		return deregisterer.Deregister()
+	}
+	return servicereply.NewNil()
+}
+
+func (p *cachedProvider) Heartbeat() servicereply.ServiceReply {
+	if heartbeater, ok := p.provider.(discoveryService.Heartbeater); ok {
+		return heartbeater.Heartbeat()
+	}
+	return servicereply.NewNil()
+}
+
+// HeartbeatInterval is zero when the wrapped provider doesn't send heartbeats, which disables them
+func (p *cachedProvider) HeartbeatInterval() time.Duration {
+	if heartbeater, ok := p.provider.(discoveryService.Heartbeater); ok {
+		return heartbeater.HeartbeatInterval()
+	}
+	return 0
+}
+
+// fetch asks the provider and caches the result. A failure is cached for NegativeTTL, the previous instances
+// are kept so they can still be served stale.
+func (p *cachedProvider) fetch(serviceName string) ([]discoveryService.Instance, servicereply.ServiceReply) {
+	p.mu.Lock()
+	if f, ok := p.inFlight[serviceName]; ok {
+		p.mu.Unlock()
+		<-f.done
+		return f.instances, f.err
+	}
+	f := &fetch{done: make(chan struct{})}
+	p.inFlight[serviceName] = f
+	p.mu.Unlock()
+
+	f.instances, f.err = p.resolve(serviceName)
+
+	p.mu.Lock()
+	delete(p.inFlight, serviceName)
+	now := p.now()
+	if f.err == nil {
+		p.entries[serviceName] = &entry{instances: f.instances, expires: now.Add(p.settings.TTL)}
+	} else if e, ok := p.entries[serviceName]; ok {
+		e.err, e.errUntil = f.err, now.Add(p.settings.NegativeTTL)
+	} else {
+		p.entries[serviceName] = &entry{err: f.err, errUntil: now.Add(p.settings.NegativeTTL)}
+	}
+	p.mu.Unlock()
+	close(f.done)
+	return f.instances, f.err
+}
+
+// stale returns the last resolved instances of a service when they expired less than MaxStale ago
+func (p *cachedProvider) stale(serviceName string) ([]discoveryService.Instance, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[serviceName]
+	if !ok || e.instances == nil || p.now().After(e.expires.Add(p.settings.MaxStale)) {
+		return nil, false
+	}
+	return e.instances, true
+}
+
+func (p *cachedProvider) resolve(serviceName string) ([]discoveryService.Instance, servicereply.ServiceReply) {
+	if resolver, ok := p.provider.(discoveryService.Resolver); ok {
+		instances, sRep := resolver.Resolve(serviceName)
+		if sRep != nil && !sRep.IsSuccess() {
+			return nil, sRep
+		}
+		if len(instances) == 0 {
+			return nil, servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s (no healthy instances)", serviceName))
+		}
+		return instances, nil
+	}
+	sRep := p.provider.GetAddress(serviceName)
+	if !sRep.IsSuccess() {
+		return nil, sRep
+	}
+	address, ok := sRep.GetReplyValues()["address"]
+	// the provider replied successfully, the failures are replied as network errors so they aren't served as
+	// successes
+	if !ok {
+		return nil, servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s", serviceName))
+	}
+	if fmt.Sprint(address) == serviceName || fmt.Sprint(address) == "" {
+		return nil, servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s (need to define env or discovery service)", serviceName))
+	}
+	return []discoveryService.Instance{{Address: fmt.Sprint(address)}}, nil
+}
+
+func (p *cachedProvider) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(p.settings.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refresh()
+		}
+	}
+}
+
+// refresh resolves again the entries that were used and expire before the next tick, entries that can't be
+// served anymore are dropped
+func (p *cachedProvider) refresh() {
+	now := p.now()
+	var names []string
+	p.mu.Lock()
+	for name, e := range p.entries {
+		if e.instances == nil && now.After(e.errUntil) || e.instances != nil && now.After(e.expires.Add(p.settings.MaxStale)) {
+			delete(p.entries, name)
+		} else if e.used && e.instances != nil && now.Add(p.settings.RefreshInterval).After(e.expires) {
+			e.used = false
+			names = append(names, name)
+		}
+	}
+	p.mu.Unlock()
+	for _, name := range names {
+		atomic.AddUint64(&p.refreshes, 1)
+		if _, sRep := p.fetch(name); sRep != nil {
+			atomic.AddUint64(&p.refreshErrors, 1)
+		}
+	}
+}
+
+var _ Provider = (*cachedProvider)(nil)
@@ -0,0 +1,125 @@
+package cachedDiscovery
+
+import (
+	"fmt"
+	"github.com/orchestd/servicereply"
+	"github.com/smartystreets/goconvey/convey"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingProvider struct {
+	calls   int32
+	mu      sync.Mutex
+	address string
+}
+
+func (c *countingProvider) Register() servicereply.ServiceReply {
+	return servicereply.NewNil()
+}
+
+func (c *countingProvider) GetAddress(serviceName string) servicereply.ServiceReply {
+	atomic.AddInt32(&c.calls, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.address == "" {
+		return servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s", serviceName))
+	}
+	return servicereply.NewNil().WithReplyValues(servicereply.ValuesMap{"address": c.address})
+}
+
+func (c *countingProvider) setAddress(address string) {
+	c.mu.Lock()
+	c.address = address
+	c.mu.Unlock()
+}
+
+func Test_CachedProvider(t *testing.T) {
+	convey.Convey("Given a cache around a provider", t, func() {
+		inner := &countingProvider{address: "http://a"}
+		clock := time.Now()
+		p := NewCachedProvider(inner, Settings{TTL: time.Minute, NegativeTTL: 10 * time.Second, MaxStale: 5 * time.Minute, RefreshInterval: -1}).(*cachedProvider)
+		p.now = func() time.Time { return clock }
+
+		convey.So(p.GetAddress("users").GetReplyValues()["address"], convey.ShouldEqual, "http://a")
+		convey.So(p.GetAddress("users").GetReplyValues()["address"], convey.ShouldEqual, "http://a")
+		convey.So(inner.calls, convey.ShouldEqual, 1)
+		convey.So(p.Stats(), convey.ShouldResemble, Stats{Hits: 1, Misses: 1})
+
+		convey.Convey("expired entries are resolved again", func() {
+			inner.setAddress("http://b")
+			clock = clock.Add(2 * time.Minute)
+			instances, sRep := p.Resolve("users")
+			convey.So(sRep, convey.ShouldBeNil)
+			convey.So(instances[0].Address, convey.ShouldEqual, "http://b")
+			convey.So(inner.calls, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("expired entries are served stale when the provider fails", func() {
+			inner.setAddress("")
+			clock = clock.Add(2 * time.Minute)
+			instances, sRep := p.Resolve("users")
+			convey.So(sRep, convey.ShouldBeNil)
+			convey.So(instances[0].Address, convey.ShouldEqual, "http://a")
+			// the failure is cached so the provider isn't asked on every call
+			p.Resolve("users")
+			convey.So(inner.calls, convey.ShouldEqual, 2)
+			convey.So(p.Stats().StaleHits, convey.ShouldEqual, 2)
+
+			clock = clock.Add(10 * time.Minute)
+			_, sRep = p.Resolve("users")
+			convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("failures are cached for the negative ttl", func() {
+			inner.setAddress("")
+			_, sRep := p.Resolve("orders")
+			convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+			_, sRep = p.Resolve("orders")
+			convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+			convey.So(inner.calls, convey.ShouldEqual, 2)
+			convey.So(p.Stats().NegativeHits, convey.ShouldEqual, 1)
+
+			inner.setAddress("http://c")
+			clock = clock.Add(11 * time.Second)
+			instances, sRep := p.Resolve("orders")
+			convey.So(sRep, convey.ShouldBeNil)
+			convey.So(instances[0].Address, convey.ShouldEqual, "http://c")
+		})
+
+		convey.Convey("invalidated entries are resolved again", func() {
+			p.Invalidate("users")
+			p.Resolve("users")
+			convey.So(inner.calls, convey.ShouldEqual, 2)
+		})
+	})
+
+	convey.Convey("Given a provider answering with the service name", t, func() {
+		inner := &countingProvider{address: "users"}
+		p := NewCachedProvider(inner, Settings{RefreshInterval: -1})
+
+		instances, sRep := p.Resolve("users")
+		convey.So(instances, convey.ShouldBeEmpty)
+		convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+		convey.So(sRep.GetError().Error(), convey.ShouldContainSubstring, "need to define env or discovery service")
+	})
+
+	convey.Convey("Given a cache refreshing in the background", t, func() {
+		inner := &countingProvider{address: "http://a"}
+		p := NewCachedProvider(inner, Settings{TTL: 40 * time.Millisecond, RefreshInterval: 10 * time.Millisecond})
+		defer p.Close()
+
+		p.Resolve("users")
+		inner.setAddress("http://b")
+		deadline := time.Now().Add(time.Second)
+		for p.Stats().Refreshes == 0 && time.Now().Before(deadline) {
+			p.Resolve("users")
+			time.Sleep(5 * time.Millisecond)
+		}
+		instances, _ := p.Resolve("users")
+		convey.So(instances[0].Address, convey.ShouldEqual, "http://b")
+		convey.So(p.Stats().Misses, convey.ShouldEqual, 1)
+	})
+}
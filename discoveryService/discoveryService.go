@@ -2,9 +2,38 @@ package discoveryService
 
 import (
 	"github.com/orchestd/servicereply"
+	"time"
 )
 
 type DiscoveryServiceProvider interface {
 	Register() servicereply.ServiceReply
 	GetAddress(serviceName string) servicereply.ServiceReply
 }
+
+// Deregisterer is implemented by providers that can remove the instance when the server stops
+type Deregisterer interface {
+	Deregister() servicereply.ServiceReply
+}
+
+// Heartbeater is implemented by providers whose registrations expire, Heartbeat renews the registration and is
+// called every HeartbeatInterval once the instance was registered. When a heartbeat fails the instance is
+// registered again.
+type Heartbeater interface {
+	Heartbeat() servicereply.ServiceReply
+	HeartbeatInterval() time.Duration
+}
+
+// Instance is a single running instance of a service
+type Instance struct {
+	Address  string            `json:"address" yaml:"address"`
+	Zone     string            `json:"zone,omitempty" yaml:"zone,omitempty"`
+	Weight   int               `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Version  string            `json:"version,omitempty" yaml:"version,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// Resolver is implemented by providers that know every healthy instance of a service, the http client balances
+// its calls between them. Providers without it are called through GetAddress.
+type Resolver interface {
+	Resolve(serviceName string) ([]Instance, servicereply.ServiceReply)
+}
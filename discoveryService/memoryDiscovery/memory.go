@@ -0,0 +1,117 @@
+package memoryDiscovery
+
+import (
+	"fmt"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/discoveryService"
+	"sync"
+)
+
+// Registry holds the instances of services running in the same process, it is mostly useful in tests. The
+// registry itself resolves every service, use Provider to get the provider of a single instance.
+type Registry struct {
+	mu       sync.RWMutex
+	services map[string][]discoveryService.Instance
+}
+
+func NewRegistry() *Registry {
+	return &Registry{services: map[string][]discoveryService.Instance{}}
+}
+
+// Add registers an instance of serviceName, an instance with the same address is replaced
+func (r *Registry) Add(serviceName string, instance discoveryService.Instance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[serviceName] = append(removeAddress(r.services[serviceName], instance.Address), instance)
+}
+
+// Remove removes the instance of serviceName with the given address
+func (r *Registry) Remove(serviceName, address string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if instances := removeAddress(r.services[serviceName], address); len(instances) > 0 {
+		r.services[serviceName] = instances
+	} else {
+		delete(r.services, serviceName)
+	}
+}
+
+func (r *Registry) Resolve(serviceName string) ([]discoveryService.Instance, servicereply.ServiceReply) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if instances := r.services[serviceName]; len(instances) > 0 {
+		return append([]discoveryService.Instance(nil), instances...), nil
+	}
+	return nil, servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s (not registered in memory)", serviceName))
+}
+
+func (r *Registry) GetAddress(serviceName string) servicereply.ServiceReply {
+	instances, sRep := r.Resolve(serviceName)
+	if sRep != nil {
+		return sRep
+	}
+	return servicereply.NewNil().WithReplyValues(servicereply.ValuesMap{"address": instances[0].Address})
+}
+
+// Register does nothing, the registry isn't an instance of a service
+func (r *Registry) Register() servicereply.ServiceReply {
+	return servicereply.NewNil()
+}
+
+// Provider returns the provider of an instance of serviceName. Its address is usually only known once the server
+// listens, so it is set with SetAddress before the server registers.
+func (r *Registry) Provider(serviceName string) *Provider {
+	return &Provider{Registry: r, serviceName: serviceName}
+}
+
+// Provider registers a single instance in the registry and resolves the other services through it
+type Provider struct {
+	*Registry
+	serviceName string
+
+	mu      sync.Mutex
+	address string
+}
+
+func (p *Provider) SetAddress(address string) {
+	p.mu.Lock()
+	p.address = address
+	p.mu.Unlock()
+}
+
+func (p *Provider) Register() servicereply.ServiceReply {
+	p.mu.Lock()
+	address := p.address
+	p.mu.Unlock()
+	if address == "" {
+		return servicereply.NewInternalServiceError(fmt.Errorf("address of %s wasn't set", p.serviceName))
+	}
+	p.Add(p.serviceName, discoveryService.Instance{Address: address})
+	return servicereply.NewNil()
+}
+
+func (p *Provider) Deregister() servicereply.ServiceReply {
+	p.mu.Lock()
+	address := p.address
+	p.mu.Unlock()
+	p.Remove(p.serviceName, address)
+	return servicereply.NewNil()
+}
+
+func removeAddress(instances []discoveryService.Instance, address string) []discoveryService.Instance {
+	var kept []discoveryService.Instance
+	for _, instance := range instances {
+		if instance.Address != address {
+			kept = append(kept, instance)
+		}
+	}
+	return kept
+}
+
+var (
+	_ discoveryService.DiscoveryServiceProvider = (*Registry)(nil)
+	_ discoveryService.Resolver                 = (*Registry)(nil)
+	_ discoveryService.DiscoveryServiceProvider = (*Provider)(nil)
+	_ discoveryService.Resolver                 = (*Provider)(nil)
+	_ discoveryService.Deregisterer             = (*Provider)(nil)
+)
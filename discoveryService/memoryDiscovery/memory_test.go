@@ -0,0 +1,35 @@
+package memoryDiscovery
+
+import (
+	"github.com/orchestd/transport/discoveryService"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	convey.Convey("Given two instances of a service sharing a registry", t, func() {
+		registry := NewRegistry()
+		a, b := registry.Provider("users"), registry.Provider("users")
+
+		convey.So(a.Register().IsSuccess(), convey.ShouldBeFalse)
+		a.SetAddress("http://127.0.0.1:9001")
+		b.SetAddress("http://127.0.0.1:9002")
+		convey.So(a.Register().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(b.Register().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(a.Register().IsSuccess(), convey.ShouldBeTrue)
+
+		instances, sRep := registry.Resolve("users")
+		convey.So(sRep, convey.ShouldBeNil)
+		convey.So(instances, convey.ShouldResemble, []discoveryService.Instance{
+			{Address: "http://127.0.0.1:9002"},
+			{Address: "http://127.0.0.1:9001"},
+		})
+		convey.So(b.GetAddress("orders").IsSuccess(), convey.ShouldBeFalse)
+
+		convey.So(b.Deregister().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(a.GetAddress("users").GetReplyValues()["address"], convey.ShouldEqual, "http://127.0.0.1:9001")
+		convey.So(a.Deregister().IsSuccess(), convey.ShouldBeTrue)
+		_, sRep = registry.Resolve("users")
+		convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,386 @@
+package staticDiscovery
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/discoveryService"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const (
+	defaultReloadInterval = 2 * time.Second
+	lockTimeout           = 5 * time.Second
+	staleLockAge          = 30 * time.Second
+)
+
+// Settings configures the static provider. Addresses are looked up in the environment first and then in the file.
+//
+// The file maps service names to one or more instances, in json or yaml according to its extension, e.g.
+//
+//	users: http://localhost:8081
+//	orders:
+//	  - http://localhost:8082
+//	  - address: http://localhost:8083
+//	    zone: b
+//	    weight: 2
+//
+// When EnvPrefix is set, the environment variable EnvPrefix + service name (upper cased, every character that
+// isn't a letter or digit replaced by "_") holds a comma separated address list, e.g. DSP_USERS_SERVICE for
+// "users-service" with the "DSP_" prefix.
+type Settings struct {
+	File           string
+	EnvPrefix      string
+	ReloadInterval time.Duration
+
+	// RegistryFile, when set together with ServiceName and Address, makes Register add the instance to that file
+	// and Deregister remove it, so several local services can find each other through a shared file. Usually it is
+	// also the File.
+	RegistryFile string
+	ServiceName  string
+	Address      string
+}
+
+// Provider is the static discovery service provider, Close stops watching the file
+type Provider interface {
+	discoveryService.DiscoveryServiceProvider
+	discoveryService.Resolver
+	discoveryService.Deregisterer
+	Close()
+}
+
+type staticProvider struct {
+	settings Settings
+
+	mu       sync.RWMutex
+	services map[string][]discoveryService.Instance
+	modTime  time.Time
+	size     int64
+	loadErr  error
+
+	cancel context.CancelFunc
+}
+
+// NewStaticProvider loads the file when there is one and reloads it whenever it changes, until Close is called
+func NewStaticProvider(settings Settings) (Provider, error) {
+	if settings.ReloadInterval <= 0 {
+		settings.ReloadInterval = defaultReloadInterval
+	}
+	p := &staticProvider{settings: settings, services: map[string][]discoveryService.Instance{}}
+	if settings.File != "" {
+		if err := p.reload(); err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.watch(ctx)
+	}
+	return p, nil
+}
+
+func (p *staticProvider) Close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
+
+func (p *staticProvider) GetAddress(serviceName string) servicereply.ServiceReply {
+	instances, sRep := p.Resolve(serviceName)
+	if sRep != nil {
+		return sRep
+	}
+	return servicereply.NewNil().WithReplyValues(servicereply.ValuesMap{"address": instances[0].Address})
+}
+
+func (p *staticProvider) Resolve(serviceName string) ([]discoveryService.Instance, servicereply.ServiceReply) {
+	if instances := p.fromEnv(serviceName); len(instances) > 0 {
+		return instances, nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if instances := p.services[serviceName]; len(instances) > 0 {
+		// callers may reorder or edit the instances, they get a copy
+		return append([]discoveryService.Instance(nil), instances...), nil
+	}
+	err := fmt.Errorf("cant resolve host:%s (not found in %s)", serviceName, p.describe())
+	if p.loadErr != nil {
+		err = fmt.Errorf("%s, last reload failed: %w", err.Error(), p.loadErr)
+	}
+	return nil, servicereply.NewNetworkError(err)
+}
+
+// Register adds the instance to the registry file, it does nothing without one
+func (p *staticProvider) Register() servicereply.ServiceReply {
+	if !p.writesRegistry() {
+		return servicereply.NewNil()
+	}
+	err := p.updateRegistry(func(services map[string][]discoveryService.Instance) {
+		instances := removeAddress(services[p.settings.ServiceName], p.settings.Address)
+		services[p.settings.ServiceName] = append(instances, discoveryService.Instance{Address: p.settings.Address})
+	})
+	if err != nil {
+		return servicereply.NewIoError(err).WithLogMessage("Cannot register in the registry file")
+	}
+	return servicereply.NewNil()
+}
+
+func (p *staticProvider) Deregister() servicereply.ServiceReply {
+	if !p.writesRegistry() {
+		return servicereply.NewNil()
+	}
+	err := p.updateRegistry(func(services map[string][]discoveryService.Instance) {
+		if instances := removeAddress(services[p.settings.ServiceName], p.settings.Address); len(instances) > 0 {
+			services[p.settings.ServiceName] = instances
+		} else {
+			delete(services, p.settings.ServiceName)
+		}
+	})
+	if err != nil {
+		return servicereply.NewIoError(err).WithLogMessage("Cannot deregister from the registry file")
+	}
+	return servicereply.NewNil()
+}
+
+var nonEnvChars = regexp.MustCompile("[^A-Z0-9]")
+
+func (p *staticProvider) fromEnv(serviceName string) []discoveryService.Instance {
+	if p.settings.EnvPrefix == "" {
+		return nil
+	}
+	value := os.Getenv(p.settings.EnvPrefix + nonEnvChars.ReplaceAllString(strings.ToUpper(serviceName), "_"))
+	var instances []discoveryService.Instance
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			instances = append(instances, discoveryService.Instance{Address: address})
+		}
+	}
+	return instances
+}
+
+func (p *staticProvider) describe() string {
+	var sources []string
+	if p.settings.EnvPrefix != "" {
+		sources = append(sources, "env "+p.settings.EnvPrefix+"*")
+	}
+	if p.settings.File != "" {
+		sources = append(sources, p.settings.File)
+	}
+	return strings.Join(sources, " or ")
+}
+
+func (p *staticProvider) watch(ctx context.Context) {
+	ticker := time.NewTicker(p.settings.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.reload(); err != nil {
+				p.mu.Lock()
+				p.loadErr = err
+				p.mu.Unlock()
+			}
+		}
+	}
+}
+
+// reload reads the file when its modification time or size changed, the previous services are kept when it
+// can't be read
+func (p *staticProvider) reload() error {
+	info, err := os.Stat(p.settings.File)
+	if os.IsNotExist(err) && p.settings.File == p.settings.RegistryFile {
+		// the shared registry file is created by the first registration
+		return nil
+	} else if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	unchanged := info.ModTime().Equal(p.modTime) && info.Size() == p.size
+	p.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	services, err := readFile(p.settings.File)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.services, p.modTime, p.size, p.loadErr = services, info.ModTime(), info.Size(), nil
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *staticProvider) writesRegistry() bool {
+	return p.settings.RegistryFile != "" && p.settings.ServiceName != "" && p.settings.Address != ""
+}
+
+// updateRegistry applies update to the registry file while holding a lock file, the file is replaced atomically
+func (p *staticProvider) updateRegistry(update func(services map[string][]discoveryService.Instance)) error {
+	path := p.settings.RegistryFile
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	services, err := readFile(path)
+	if os.IsNotExist(err) {
+		services = map[string][]discoveryService.Instance{}
+	} else if err != nil {
+		return err
+	}
+	update(services)
+	b, err := marshal(path, services)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if path == p.settings.File {
+		return p.reload()
+	}
+	return nil
+}
+
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		// a process that died while holding the lock leaves it behind
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func removeAddress(instances []discoveryService.Instance, address string) []discoveryService.Instance {
+	var kept []discoveryService.Instance
+	for _, instance := range instances {
+		if instance.Address != address {
+			kept = append(kept, instance)
+		}
+	}
+	return kept
+}
+
+func isYaml(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func readFile(path string) (map[string][]discoveryService.Instance, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]instanceList
+	if isYaml(path) {
+		err = yaml.Unmarshal(b, &entries)
+	} else if len(strings.TrimSpace(string(b))) > 0 {
+		err = json.Unmarshal(b, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	services := make(map[string][]discoveryService.Instance, len(entries))
+	for name, instances := range entries {
+		services[name] = instances
+	}
+	return services, nil
+}
+
+func marshal(path string, services map[string][]discoveryService.Instance) ([]byte, error) {
+	if isYaml(path) {
+		return yaml.Marshal(services)
+	}
+	return json.MarshalIndent(services, "", "  ")
+}
+
+// instanceList reads a single address, or a list of addresses and instance objects
+type instanceList []discoveryService.Instance
+
+type instanceEntry discoveryService.Instance
+
+func (l *instanceList) UnmarshalJSON(b []byte) error {
+	return l.unmarshal(func(v interface{}) error { return json.Unmarshal(b, v) })
+}
+
+func (l *instanceList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return l.unmarshal(unmarshal)
+}
+
+func (l *instanceList) unmarshal(unmarshal func(interface{}) error) error {
+	var address string
+	if err := unmarshal(&address); err == nil {
+		*l = instanceList{{Address: address}}
+		return nil
+	}
+	var entries []instanceEntry
+	if err := unmarshal(&entries); err != nil {
+		return err
+	}
+	*l = make(instanceList, len(entries))
+	for i, e := range entries {
+		(*l)[i] = discoveryService.Instance(e)
+	}
+	return nil
+}
+
+func (e *instanceEntry) UnmarshalJSON(b []byte) error {
+	return e.unmarshal(func(v interface{}) error { return json.Unmarshal(b, v) })
+}
+
+func (e *instanceEntry) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return e.unmarshal(unmarshal)
+}
+
+func (e *instanceEntry) unmarshal(unmarshal func(interface{}) error) error {
+	var address string
+	if err := unmarshal(&address); err == nil {
+		*e = instanceEntry{Address: address}
+		return nil
+	}
+	var instance discoveryService.Instance
+	if err := unmarshal(&instance); err != nil {
+		return err
+	}
+	*e = instanceEntry(instance)
+	return nil
+}
+
+var _ Provider = (*staticProvider)(nil)
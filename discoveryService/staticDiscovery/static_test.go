@@ -0,0 +1,81 @@
+package staticDiscovery
+
+import (
+	"github.com/orchestd/transport/discoveryService"
+	"github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_StaticProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	convey.Convey("Given a yaml services file", t, func() {
+		file := filepath.Join(dir, "services.yaml")
+		convey.So(os.WriteFile(file, []byte("users: http://localhost:8081\norders:\n  - http://localhost:8082\n  - address: http://localhost:8083\n    zone: b\n    weight: 2\n"), 0644), convey.ShouldBeNil)
+		p, err := NewStaticProvider(Settings{File: file, EnvPrefix: "TEST_DSP_", ReloadInterval: 5 * time.Millisecond})
+		convey.So(err, convey.ShouldBeNil)
+		defer p.Close()
+
+		sRep := p.GetAddress("users")
+		convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+		convey.So(sRep.GetReplyValues()["address"], convey.ShouldEqual, "http://localhost:8081")
+		instances, sRep := p.Resolve("orders")
+		convey.So(sRep, convey.ShouldBeNil)
+		convey.So(instances, convey.ShouldResemble, []discoveryService.Instance{
+			{Address: "http://localhost:8082"},
+			{Address: "http://localhost:8083", Zone: "b", Weight: 2},
+		})
+		convey.So(p.GetAddress("payments").IsSuccess(), convey.ShouldBeFalse)
+
+		convey.Convey("resolved instances can be edited by the caller", func() {
+			instances[0], instances[1] = instances[1], instances[0]
+			instances[0].Weight = 5
+			again, _ := p.Resolve("orders")
+			convey.So(again[0], convey.ShouldResemble, discoveryService.Instance{Address: "http://localhost:8082"})
+			convey.So(again[1].Weight, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("environment variables take precedence", func() {
+			os.Setenv("TEST_DSP_USERS_SERVICE", "http://a, http://b")
+			defer os.Unsetenv("TEST_DSP_USERS_SERVICE")
+			instances, _ := p.Resolve("users-service")
+			convey.So(instances, convey.ShouldResemble, []discoveryService.Instance{{Address: "http://a"}, {Address: "http://b"}})
+		})
+
+		convey.Convey("changes to the file are reloaded", func() {
+			convey.So(os.WriteFile(file, []byte("payments: [http://localhost:8084]\n"), 0644), convey.ShouldBeNil)
+			convey.So(waitFor(func() bool { return p.GetAddress("payments").IsSuccess() }), convey.ShouldBeTrue)
+		})
+	})
+
+	convey.Convey("Given services sharing a json registry file", t, func() {
+		file := filepath.Join(dir, "registry.json")
+		users, err := NewStaticProvider(Settings{File: file, RegistryFile: file, ServiceName: "users", Address: "http://localhost:9001", ReloadInterval: 5 * time.Millisecond})
+		convey.So(err, convey.ShouldBeNil)
+		defer users.Close()
+		orders, err := NewStaticProvider(Settings{File: file, RegistryFile: file, ServiceName: "orders", Address: "http://localhost:9002", ReloadInterval: 5 * time.Millisecond})
+		convey.So(err, convey.ShouldBeNil)
+		defer orders.Close()
+
+		convey.So(users.Register().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(orders.Register().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(waitFor(func() bool { return users.GetAddress("orders").IsSuccess() }), convey.ShouldBeTrue)
+		convey.So(orders.GetAddress("users").GetReplyValues()["address"], convey.ShouldEqual, "http://localhost:9001")
+
+		convey.So(orders.Deregister().IsSuccess(), convey.ShouldBeTrue)
+		convey.So(waitFor(func() bool { return !users.GetAddress("orders").IsSuccess() }), convey.ShouldBeTrue)
+	})
+}
+
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/discoveryService/cachedDiscovery"
+	"github.com/orchestd/transport/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/fx"
+)
+
+const (
+	defaultNamespace = "orchestd"
+
+	// Path the system handler serves the metrics on
+	Path = "/metrics"
+)
+
+// Settings of the metrics, all optional
+type Settings struct {
+	// Namespace prefixes the metric names, "orchestd" by default
+	Namespace string
+	// Buckets of the duration histograms in seconds, prometheus.DefBuckets by default
+	Buckets []float64
+	// Registry the metrics are registered to and served from, the prometheus default registry when nil
+	Registry *prometheus.Registry
+}
+
+// Metrics are the collectors of the server and client interceptors
+type Metrics struct {
+	// ServerDuration is labelled with the route template, method, http code and servicereply status
+	ServerDuration *prometheus.HistogramVec
+	// ServerInFlight is labelled with the route template
+	ServerInFlight *prometheus.GaugeVec
+	// ClientDuration is labelled with the target service name, method and http code, the code is "error" when no
+	// response was received
+	ClientDuration *prometheus.HistogramVec
+	// ClientInFlight is labelled with the target service name
+	ClientInFlight *prometheus.GaugeVec
+	// DiscoveryFailures counts the calls whose target service name couldn't be resolved
+	DiscoveryFailures *prometheus.CounterVec
+
+	namespace  string
+	registerer prometheus.Registerer
+	gatherer   prometheus.Gatherer
+}
+
+func NewMetrics(settings Settings) (*Metrics, error) {
+	if settings.Namespace == "" {
+		settings.Namespace = defaultNamespace
+	}
+	if len(settings.Buckets) == 0 {
+		settings.Buckets = prometheus.DefBuckets
+	}
+	var registerer prometheus.Registerer = prometheus.DefaultRegisterer
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if settings.Registry != nil {
+		registerer, gatherer = settings.Registry, settings.Registry
+	}
+	m := &Metrics{
+		ServerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: settings.Namespace,
+			Subsystem: "server",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the requests served, by route template, method, http code and reply status.",
+			Buckets:   settings.Buckets,
+		}, []string{"route", "method", "code", "status"}),
+		ServerInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: settings.Namespace,
+			Subsystem: "server",
+			Name:      "requests_in_flight",
+			Help:      "Requests being served, by route template.",
+		}, []string{"route"}),
+		ClientDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: settings.Namespace,
+			Subsystem: "client",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the calls sent, by target service, method and http code.",
+			Buckets:   settings.Buckets,
+		}, []string{"service", "method", "code"}),
+		ClientInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: settings.Namespace,
+			Subsystem: "client",
+			Name:      "requests_in_flight",
+			Help:      "Calls waiting for a response, by target service.",
+		}, []string{"service"}),
+		DiscoveryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: settings.Namespace,
+			Subsystem: "client",
+			Name:      "discovery_failures_total",
+			Help:      "Calls that failed because their target service couldn't be resolved.",
+		}, []string{"service"}),
+		namespace:  settings.Namespace,
+		registerer: registerer,
+		gatherer:   gatherer,
+	}
+	for _, c := range []prometheus.Collector{m.ServerDuration, m.ServerInFlight, m.ClientDuration, m.ClientInFlight,
+		m.DiscoveryFailures} {
+		if err := registerer.Register(c); err != nil {
+			return nil, fmt.Errorf("cannot register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Handler is the system handler serving the metrics on Path
+func (m *Metrics) Handler() server.IHandler {
+	return &server.Handler{
+		HttpType: server.MethodGet,
+		Method:   Path,
+		Handler:  []gin.HandlerFunc{gin.WrapH(promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{}))},
+	}
+}
+
+// ResolveFailureHook counts the resolution failures of a client, see client.HTTPClientBuilder.AddResolveFailureHooks
+func (m *Metrics) ResolveFailureHook(serviceName string, _ servicereply.ServiceReply) {
+	m.DiscoveryFailures.WithLabelValues(serviceName).Inc()
+}
+
+// RegisterDiscoveryCache serves the Stats of a cachedDiscovery.Provider with the metrics, they are read on every
+// scrape. A single cache can be registered.
+func (m *Metrics) RegisterDiscoveryCache(cache cachedDiscovery.Provider) error {
+	if err := m.registerer.Register(newDiscoveryCacheCollector(m.namespace, cache)); err != nil {
+		return fmt.Errorf("cannot register discovery cache metrics: %w", err)
+	}
+	return nil
+}
+
+// discoveryCacheCollector reads the counters of a cache when the metrics are gathered
+type discoveryCacheCollector struct {
+	cache         cachedDiscovery.Provider
+	resolutions   *prometheus.Desc
+	refreshes     *prometheus.Desc
+	refreshErrors *prometheus.Desc
+}
+
+func newDiscoveryCacheCollector(namespace string, cache cachedDiscovery.Provider) *discoveryCacheCollector {
+	return &discoveryCacheCollector{
+		cache: cache,
+		resolutions: prometheus.NewDesc(prometheus.BuildFQName(namespace, "discovery_cache", "resolutions_total"),
+			"Resolutions served by the discovery cache, by result: hit, miss, stale or negative.", []string{"result"}, nil),
+		refreshes: prometheus.NewDesc(prometheus.BuildFQName(namespace, "discovery_cache", "refreshes_total"),
+			"Background refreshes of the discovery cache.", nil, nil),
+		refreshErrors: prometheus.NewDesc(prometheus.BuildFQName(namespace, "discovery_cache", "refresh_errors_total"),
+			"Background refreshes of the discovery cache that failed.", nil, nil),
+	}
+}
+
+func (c *discoveryCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.resolutions
+	ch <- c.refreshes
+	ch <- c.refreshErrors
+}
+
+func (c *discoveryCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.resolutions, prometheus.CounterValue, float64(stats.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(c.resolutions, prometheus.CounterValue, float64(stats.Misses), "miss")
+	ch <- prometheus.MustNewConstMetric(c.resolutions, prometheus.CounterValue, float64(stats.StaleHits), "stale")
+	ch <- prometheus.MustNewConstMetric(c.resolutions, prometheus.CounterValue, float64(stats.NegativeHits), "negative")
+	ch <- prometheus.MustNewConstMetric(c.refreshes, prometheus.CounterValue, float64(stats.Refreshes))
+	ch <- prometheus.MustNewConstMetric(c.refreshErrors, prometheus.CounterValue, float64(stats.RefreshErrors))
+}
+
+// Params are the dependencies New takes from the fx graph
+type Params struct {
+	fx.In
+
+	Settings *Settings `optional:"true"`
+}
+
+func New(p Params) (*Metrics, error) {
+	if p.Settings == nil {
+		return NewMetrics(Settings{})
+	}
+	return NewMetrics(*p.Settings)
+}
+
+// Module provides the *Metrics the interceptors are built with
+var Module = fx.Provide(New)
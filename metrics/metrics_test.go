@@ -0,0 +1,111 @@
+package metrics_test
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	clientHttp "github.com/orchestd/transport/client/http"
+	clientMetrics "github.com/orchestd/transport/client/http/interceptors/metrics"
+	"github.com/orchestd/transport/discoveryService/cachedDiscovery"
+	"github.com/orchestd/transport/metrics"
+	"github.com/orchestd/transport/server"
+	"github.com/orchestd/transport/server/http"
+	serverMetrics "github.com/orchestd/transport/server/http/interceptors/metrics"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx"
+	"io/ioutil"
+	nethttp "net/http"
+	"strings"
+	"testing"
+)
+
+func Test_Metrics(t *testing.T) {
+	m, err := metrics.NewMetrics(metrics.Settings{Registry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inFlight float64
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name: "users",
+		Builder: func() server.HttpBuilder {
+			return http.Builder().AddRouterInterceptors(serverMetrics.Metrics(m)).AddSystemHandlers(m.Handler())
+		},
+		Routes: func(router gin.IRouter) {
+			router.GET("/users/:id", func(c *gin.Context) {
+				inFlight = testutil.ToFloat64(m.ServerInFlight.WithLabelValues("/users/:id"))
+				http.GinErrorReply(c, servicereply.NewNoMatchReply("userNotFound"), nil)
+			})
+		},
+	})
+	httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+		AddInterceptors(clientMetrics.Metrics(m)).AddResolveFailureHooks(m.ResolveFailureHook))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convey.Convey("Given calls to a service", t, func() {
+		httpClient.Get(context.Background(), "users", "users/1", nil, nil)
+		httpClient.Get(context.Background(), "users", "users/2", nil, nil)
+		httpClient.Get(context.Background(), "orders", "orders/1", nil, nil)
+
+		convey.So(inFlight, convey.ShouldEqual, 1)
+		convey.So(testutil.ToFloat64(m.ServerInFlight.WithLabelValues("/users/:id")), convey.ShouldEqual, 0)
+		convey.So(testutil.ToFloat64(m.DiscoveryFailures.WithLabelValues("orders")), convey.ShouldEqual, 1)
+
+		convey.Convey("the metrics endpoint serves them by route and target service", func() {
+			res, err := nethttp.Get(cluster.Addresses("users")[0] + metrics.Path)
+			convey.So(err, convey.ShouldBeNil)
+			defer res.Body.Close()
+			body, _ := ioutil.ReadAll(res.Body)
+			convey.So(string(body), convey.ShouldContainSubstring,
+				`orchestd_server_request_duration_seconds_count{code="200",method="GET",route="/users/:id",status="noMatch"} 2`)
+			convey.So(string(body), convey.ShouldContainSubstring,
+				`orchestd_client_request_duration_seconds_count{code="200",method="GET",service="users"} 2`)
+			convey.So(string(body), convey.ShouldContainSubstring, `orchestd_client_discovery_failures_total{service="orders"} 1`)
+			convey.So(string(body), convey.ShouldContainSubstring, `orchestd_client_requests_in_flight{service="users"} 0`)
+		})
+	})
+
+	convey.Convey("Given an application providing the metrics", t, func() {
+		registry := prometheus.NewRegistry()
+		var provided *metrics.Metrics
+		app := fx.New(fx.NopLogger, metrics.Module, fx.Supply(&metrics.Settings{Namespace: "shop", Registry: registry}),
+			fx.Populate(&provided))
+		convey.So(app.Err(), convey.ShouldBeNil)
+		provided.DiscoveryFailures.WithLabelValues("users").Inc()
+		count, err := testutil.GatherAndCount(registry, "shop_client_discovery_failures_total")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(count, convey.ShouldEqual, 1)
+
+		_, err = metrics.NewMetrics(metrics.Settings{Namespace: "shop", Registry: registry})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Given a discovery cache", t, func() {
+		registry := prometheus.NewRegistry()
+		m, err := metrics.NewMetrics(metrics.Settings{Registry: registry})
+		convey.So(err, convey.ShouldBeNil)
+		cache := cachedDiscovery.NewCachedProvider(cluster.Registry().Provider("users"),
+			cachedDiscovery.Settings{RefreshInterval: -1})
+		defer cache.Close()
+		convey.So(m.RegisterDiscoveryCache(cache), convey.ShouldBeNil)
+		cache.Resolve("users")
+		cache.Resolve("users")
+
+		convey.Convey("its stats are served with the metrics", func() {
+			expected := `
+# HELP orchestd_discovery_cache_resolutions_total Resolutions served by the discovery cache, by result: hit, miss, stale or negative.
+# TYPE orchestd_discovery_cache_resolutions_total counter
+orchestd_discovery_cache_resolutions_total{result="hit"} 1
+orchestd_discovery_cache_resolutions_total{result="miss"} 1
+orchestd_discovery_cache_resolutions_total{result="negative"} 0
+orchestd_discovery_cache_resolutions_total{result="stale"} 0
+`
+			convey.So(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+				"orchestd_discovery_cache_resolutions_total"), convey.ShouldBeNil)
+		})
+	})
+}
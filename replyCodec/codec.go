@@ -0,0 +1,238 @@
+package replyCodec
+
+import (
+	"bytes"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/orchestd/servicereply"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const (
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEMsgPack  = "application/x-msgpack"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+// aliases maps alternative mime types to the one used by a codec
+var aliases = map[string]string{
+	"text/json":              MIMEJSON,
+	"text/xml":               MIMEXML,
+	"application/msgpack":    MIMEMsgPack,
+	"application/protobuf":   MIMEProtobuf,
+	"application/x-protobuf": MIMEProtobuf,
+}
+
+// Codec encodes and decodes the servicereply.Response envelope in a single wire format
+type Codec interface {
+	ContentType() string
+	Marshal(res servicereply.Response) ([]byte, error)
+	Unmarshal(data []byte, res *servicereply.Response) error
+}
+
+var codecs = map[string]Codec{
+	MIMEJSON:     jsonCodec{},
+	MIMEXML:      xmlCodec{},
+	MIMEMsgPack:  msgPackCodec{},
+	MIMEProtobuf: protobufCodec{},
+}
+
+// ContentTypes lists every content type that has a codec, JSON first as it is the default
+func ContentTypes() []string {
+	return []string{MIMEJSON, MIMEXML, MIMEMsgPack, MIMEProtobuf}
+}
+
+// ForContentType returns the codec of a Content-Type header value, falling back to JSON when it is unknown or empty
+func ForContentType(contentType string) Codec {
+	if c, ok := codecs[Normalize(contentType)]; ok {
+		return c
+	}
+	return codecs[MIMEJSON]
+}
+
+// Negotiate picks the codec that best matches an Accept header out of the allowed content types, ranges of equal
+// quality and wildcards match the allowed content types in their order. When nothing matches the first allowed
+// content type is used. Only JSON is allowed when allowed is empty, the other formats are opt-in.
+func Negotiate(accept string, allowed []string) Codec {
+	if len(allowed) == 0 {
+		return codecs[MIMEJSON]
+	}
+	ranges := parseAccept(accept)
+	for start := 0; start < len(ranges); {
+		end := start + 1
+		for end < len(ranges) && ranges[end].q == ranges[start].q {
+			end++
+		}
+		for _, ct := range allowed {
+			ct = Normalize(ct)
+			for _, r := range ranges[start:end] {
+				if matches(r.value, ct) {
+					if c, ok := codecs[ct]; ok {
+						return c
+					}
+				}
+			}
+		}
+		start = end
+	}
+	return ForContentType(allowed[0])
+}
+
+// IsSupported tells if the content type has a codec
+func IsSupported(contentType string) bool {
+	_, ok := codecs[Normalize(contentType)]
+	return ok
+}
+
+// Normalize returns the content type a codec is registered with for a mime type or one of its aliases, e.g.
+// application/xml for "text/xml; charset=utf-8"
+func Normalize(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+func matches(mediaRange, contentType string) bool {
+	return mediaRange == "*/*" || mediaRange == contentType ||
+		(strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(mediaRange, "*")))
+}
+
+type mediaRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by their quality, dropping the rejected ones
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		r := mediaRange{value: Normalize(part), q: 1}
+		if _, params, err := mime.ParseMediaType(part); err == nil {
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+				r.q = q
+			}
+		}
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// toTree converts the envelope to plain maps, slices and scalars so that formats without struct support can
+// encode it the same way JSON does
+func toTree(res servicereply.Response) (map[string]interface{}, error) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+func fromTree(tree interface{}, res *servicereply.Response) error {
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, res)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return MIMEJSON
+}
+
+func (jsonCodec) Marshal(res servicereply.Response) ([]byte, error) {
+	return json.Marshal(res)
+}
+
+func (jsonCodec) Unmarshal(data []byte, res *servicereply.Response) error {
+	return json.Unmarshal(data, res)
+}
+
+type msgPackCodec struct{}
+
+func (msgPackCodec) handle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	return h
+}
+
+func (m msgPackCodec) ContentType() string {
+	return MIMEMsgPack
+}
+
+func (m msgPackCodec) Marshal(res servicereply.Response) ([]byte, error) {
+	tree, err := toTree(res)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, m.handle()).Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m msgPackCodec) Unmarshal(data []byte, res *servicereply.Response) error {
+	var tree map[string]interface{}
+	if err := codec.NewDecoderBytes(data, m.handle()).Decode(&tree); err != nil {
+		return err
+	}
+	return fromTree(tree, res)
+}
+
+// protobufCodec sends the envelope as a google.protobuf.Struct message, so no generated code is needed on
+// either side
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return MIMEProtobuf
+}
+
+func (protobufCodec) Marshal(res servicereply.Response) ([]byte, error) {
+	tree, err := toTree(res)
+	if err != nil {
+		return nil, err
+	}
+	s, err := structpb.NewStruct(tree)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert response to protobuf struct: %w", err)
+	}
+	return proto.Marshal(s)
+}
+
+func (protobufCodec) Unmarshal(data []byte, res *servicereply.Response) error {
+	var s structpb.Struct
+	if err := proto.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return fromTree(s.AsMap(), res)
+}
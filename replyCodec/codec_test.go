@@ -0,0 +1,49 @@
+package replyCodec
+
+import (
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/servicereply/status"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	res := servicereply.Response{
+		BaseResponse: servicereply.BaseResponse{
+			Status:  status.InvalidStatus,
+			Message: &servicereply.Message{Id: "invalidRequest", Values: map[string]interface{}{"fields": []interface{}{"a b", 1.5}}},
+		},
+		Data: map[string]interface{}{"hello": "world", "count": float64(2), "ok": true, "none": nil, "with space": []interface{}{}},
+	}
+
+	convey.Convey("Given a response envelope", t, func() {
+		for _, ct := range ContentTypes() {
+			convey.Convey("it survives a round trip through "+ct, func() {
+				codec := ForContentType(ct)
+				convey.So(codec.ContentType(), convey.ShouldEqual, ct)
+				b, err := codec.Marshal(res)
+				convey.So(err, convey.ShouldBeNil)
+				var decoded servicereply.Response
+				convey.So(codec.Unmarshal(b, &decoded), convey.ShouldBeNil)
+				convey.So(decoded, convey.ShouldResemble, res)
+			})
+		}
+	})
+}
+
+func Test_Negotiate(t *testing.T) {
+	convey.Convey("Given an Accept header", t, func() {
+		convey.So(Negotiate("", nil).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("*/*", nil).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("text/xml", nil).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", nil).ContentType(), convey.ShouldEqual, MIMEJSON)
+		all := ContentTypes()
+		convey.So(Negotiate("text/xml", all).ContentType(), convey.ShouldEqual, MIMEXML)
+		convey.So(Negotiate("application/xml, application/json", all).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("application/json;q=0.5, application/msgpack", all).ContentType(), convey.ShouldEqual, MIMEMsgPack)
+		convey.So(Negotiate("application/x-protobuf;q=0, application/json", all).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("application/x-msgpack", []string{"application/msgpack"}).ContentType(), convey.ShouldEqual, MIMEMsgPack)
+		convey.So(Negotiate("application/xml", []string{MIMEJSON, MIMEProtobuf}).ContentType(), convey.ShouldEqual, MIMEJSON)
+		convey.So(Negotiate("text/html", []string{MIMEXML}).ContentType(), convey.ShouldEqual, MIMEXML)
+	})
+}
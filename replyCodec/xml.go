@@ -0,0 +1,203 @@
+package replyCodec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/orchestd/servicereply"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	xmlRootElement  = "response"
+	xmlItemElement  = "item"
+	xmlEntryElement = "entry"
+	xmlTypeAttr     = "type"
+	xmlKeyAttr      = "key"
+
+	xmlTypeObject  = "object"
+	xmlTypeArray   = "array"
+	xmlTypeNumber  = "number"
+	xmlTypeBoolean = "boolean"
+	xmlTypeNull    = "null"
+)
+
+// xmlCodec writes the envelope as elements named after the json keys. Arrays hold <item> elements, keys that
+// aren't valid element names are written as <entry key="...">, and non string values carry a type attribute
+// so they can be read back without a schema, e.g.
+//
+//	<response><status>success</status><data type="object"><count type="number">2</count></data></response>
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return MIMEXML
+}
+
+func (xmlCodec) Marshal(res servicereply.Response) ([]byte, error) {
+	tree, err := toTree(res)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLValue(enc, xmlRootElement, tree); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (xmlCodec) Unmarshal(data []byte, res *servicereply.Response) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			_, tree, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return err
+			}
+			return fromTree(tree, res)
+		}
+	}
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start.Name.Local = xmlEntryElement
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlKeyAttr}, Value: name})
+	}
+	typeAttr := func(t string) {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlTypeAttr}, Value: t})
+	}
+	switch val := v.(type) {
+	case nil:
+		typeAttr(xmlTypeNull)
+		return enc.EncodeElement("", start)
+	case string:
+		return enc.EncodeElement(val, start)
+	case float64:
+		typeAttr(xmlTypeNumber)
+		return enc.EncodeElement(strconv.FormatFloat(val, 'f', -1, 64), start)
+	case bool:
+		typeAttr(xmlTypeBoolean)
+		return enc.EncodeElement(strconv.FormatBool(val), start)
+	case []interface{}:
+		typeAttr(xmlTypeArray)
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range val {
+			if err := encodeXMLValue(enc, xmlItemElement, item); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case map[string]interface{}:
+		if name != xmlRootElement {
+			typeAttr(xmlTypeObject)
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLValue(enc, k, val[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return fmt.Errorf("cannot encode %T to xml", v)
+	}
+}
+
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (string, interface{}, error) {
+	key, typ := start.Name.Local, ""
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case xmlKeyAttr:
+			key = attr.Value
+		case xmlTypeAttr:
+			typ = attr.Value
+		}
+	}
+	var text strings.Builder
+	var keys []string
+	var values []interface{}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			k, v, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return "", nil, err
+			}
+			keys = append(keys, k)
+			values = append(values, v)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			v, err := xmlValue(typ, text.String(), keys, values)
+			return key, v, err
+		}
+	}
+}
+
+func xmlValue(typ, text string, keys []string, values []interface{}) (interface{}, error) {
+	switch typ {
+	case xmlTypeNull:
+		return nil, nil
+	case xmlTypeNumber:
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case xmlTypeBoolean:
+		return strconv.ParseBool(strings.TrimSpace(text))
+	case xmlTypeArray:
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+	case xmlTypeObject:
+	default:
+		if len(keys) == 0 {
+			return text, nil
+		}
+	}
+	obj := make(map[string]interface{}, len(keys))
+	for i, k := range keys {
+		obj[k] = values[i]
+	}
+	return obj, nil
+}
+
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || unicode.IsLetter(r) {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
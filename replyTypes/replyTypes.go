@@ -0,0 +1,66 @@
+package replyTypes
+
+import (
+	"github.com/orchestd/servicereply"
+	httpError "github.com/orchestd/servicereply/http"
+	"github.com/orchestd/servicereply/status"
+	"github.com/orchestd/servicereply/types"
+	"net/http"
+)
+
+// reply types added by the transport, servicereply doesn't know their http code and status so the server reads
+// them with GetHttpCode and GetStatus
+const (
+	// CircuitOpenReplyType is returned without calling a service whose circuit breaker is open
+	CircuitOpenReplyType types.ReplyType = "circuitOpen"
+	// TimeoutReplyType is returned when a call didn't complete in time
+	TimeoutReplyType types.ReplyType = "timeout"
+
+	CircuitOpenUserMessage = "serviceUnavailable"
+	TimeoutUserMessage     = "timeout"
+)
+
+var httpCodes = map[types.ReplyType]int{
+	CircuitOpenReplyType: http.StatusServiceUnavailable,
+	TimeoutReplyType:     http.StatusGatewayTimeout,
+}
+
+var statuses = map[types.ReplyType]status.Status{
+	CircuitOpenReplyType: status.ErrorStatus,
+	TimeoutReplyType:     status.ErrorStatus,
+}
+
+// GetHttpCode is servicereply's http.GetHttpCode knowing the transport reply types
+func GetHttpCode(et *types.ReplyType) int {
+	if et != nil {
+		if code, ok := httpCodes[*et]; ok {
+			return code
+		}
+	}
+	return httpError.GetHttpCode(et)
+}
+
+// GetStatus is servicereply's status.GetStatus knowing the transport reply types
+func GetStatus(et *types.ReplyType) status.Status {
+	if et != nil {
+		if s, ok := statuses[*et]; ok {
+			return s
+		}
+	}
+	return status.GetStatus(et)
+}
+
+func NewCircuitOpenError(err error) servicereply.ServiceReply {
+	et := CircuitOpenReplyType
+	return servicereply.NewServiceError(&et, err, CircuitOpenUserMessage, 1)
+}
+
+func NewTimeoutError(err error) servicereply.ServiceReply {
+	et := TimeoutReplyType
+	return servicereply.NewServiceError(&et, err, TimeoutUserMessage, 1)
+}
+
+// Is tells whether reply is of the given type
+func Is(reply servicereply.ServiceReply, et types.ReplyType) bool {
+	return reply != nil && reply.GetErrorType() != nil && *reply.GetErrorType() == et
+}
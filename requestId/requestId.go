@@ -0,0 +1,50 @@
+package requestId
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header carries the request id between services and back to the caller
+const Header = "X-Request-ID"
+
+const maxLength = 128
+
+type requestIdKey struct{}
+
+// WithRequestId returns a context carrying the request id, the server interceptor sets it on every request
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// FromContext returns the request id of the request ctx belongs to
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIdKey{}).(string)
+	return id, ok && id != ""
+}
+
+// New returns a random request id
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// IsValid tells whether an id received from a caller can be reused, ids are logged so only short ids of
+// letters, digits and "-", "_", ".", ":" are kept
+func IsValid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package requestId_test
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	clientRequestId "github.com/orchestd/transport/client/http/interceptors/requestId"
+	"github.com/orchestd/transport/requestId"
+	"github.com/orchestd/transport/server"
+	"github.com/orchestd/transport/server/http"
+	serverRequestId "github.com/orchestd/transport/server/http/interceptors/requestId"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	nethttp "net/http"
+	"strings"
+	"testing"
+)
+
+func Test_RequestId(t *testing.T) {
+	var ordersId string
+	var httpLog http.HttpLog
+	var ordersClient client.HttpClient
+	withId := func() server.HttpBuilder {
+		return http.Builder().AddRouterInterceptors(serverRequestId.RequestId())
+	}
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name:    "users",
+		Builder: withId,
+		Routes: func(router gin.IRouter) {
+			router.GET("/getUser", func(c *gin.Context) {
+				ordersClient.Get(c.Request.Context(), "orders", "getOrders", nil, nil)
+				http.GinSuccessReply(c, nil)
+			})
+		},
+	}, transportTest.Service{
+		Name:    "orders",
+		Builder: withId,
+		Routes: func(router gin.IRouter) {
+			router.GET("/getOrders", func(c *gin.Context) {
+				ordersId, _ = requestId.FromContext(c.Request.Context())
+				http.GinErrorReply(c, servicereply.NewNoMatchReply("noOrders"), nil)
+				httpLog = c.Errors.Last().Meta.(http.HttpLog)
+			})
+		},
+	})
+	var err error
+	ordersClient, err = cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+		AddInterceptors(clientRequestId.RequestId()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	getUser := func(id string) string {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, cluster.Addresses("users")[0]+"/getUser", nil)
+		if id != "" {
+			req.Header.Set(requestId.Header, id)
+		}
+		res, err := nethttp.DefaultClient.Do(req)
+		convey.So(err, convey.ShouldBeNil)
+		res.Body.Close()
+		return res.Header.Get(requestId.Header)
+	}
+
+	convey.Convey("Given a request going through two services", t, func() {
+		convey.Convey("the id of the caller is forwarded and echoed", func() {
+			convey.So(getUser("abc-123"), convey.ShouldEqual, "abc-123")
+			convey.So(ordersId, convey.ShouldEqual, "abc-123")
+			convey.So(httpLog.GetRequestId(), convey.ShouldEqual, "abc-123")
+		})
+
+		convey.Convey("an id is generated when the caller has none", func() {
+			id := getUser("")
+			convey.So(id, convey.ShouldHaveLength, 32)
+			convey.So(ordersId, convey.ShouldEqual, id)
+		})
+
+		convey.Convey("an invalid id is replaced", func() {
+			id := getUser(strings.Repeat("a", 200))
+			convey.So(id, convey.ShouldHaveLength, 32)
+			convey.So(getUser("a b"), convey.ShouldNotEqual, "a b")
+		})
+	})
+}
@@ -5,16 +5,23 @@ import (
 	"github.com/orchestd/dependencybundler/interfaces/log"
 	"github.com/orchestd/transport/discoveryService"
 	"go.uber.org/fx"
+	"net"
 	"time"
 )
 
 type HTTPType string
 
 const (
-	MethodGet    HTTPType = "GET"
-	MethodPost   HTTPType = "POST"
-	MethodPut    HTTPType = "PUT"
-	MethodDelete HTTPType = "DELETE"
+	MethodGet     HTTPType = "GET"
+	MethodPost    HTTPType = "POST"
+	MethodPut     HTTPType = "PUT"
+	MethodDelete  HTTPType = "DELETE"
+	MethodPatch   HTTPType = "PATCH"
+	MethodHead    HTTPType = "HEAD"
+	MethodOptions HTTPType = "OPTIONS"
+
+	// MethodWebSocket handlers are registered as GET routes on the api group, see HttpBuilder.AddWebSocketHandlers
+	MethodWebSocket HTTPType = "WEBSOCKET"
 )
 
 type Handler struct {
@@ -45,6 +52,14 @@ func NewHttpHandler(httpType HTTPType, method string, handler ...gin.HandlerFunc
 	}
 }
 
+func NewWebSocketHandler(method string, handler ...gin.HandlerFunc) func() IHandler {
+	return NewHttpHandler(MethodWebSocket, method, handler...)
+}
+
+// PanicHook is called with every panic recovered while serving a request, after it was logged and before the
+// error reply is written, e.g. to alert
+type PanicHook func(c *gin.Context, recovered interface{}, stack []byte)
+
 type IHandler interface {
 	GetHttpType() HTTPType
 	GetMethod() string
@@ -56,10 +71,39 @@ type HttpBuilder interface {
 	SetStatics(statics map[string]string) HttpBuilder
 	SetWriteTimeout(d time.Duration) HttpBuilder
 	SetReadTimeout(d time.Duration) HttpBuilder
+	// SetDrainPeriod is how long the server keeps serving after it stopped being ready and was deregistered,
+	// before shutting down. Sse streams are then cancelled while the other requests may finish until the stop
+	// deadline, the contexts of those still running after it are cancelled.
+	SetDrainPeriod(d time.Duration) HttpBuilder
 	SetLogger(logger log.Logger) HttpBuilder
+	// SetShutdowner lets the server stop the application when it fails serving after it started, without it the
+	// failure is only logged
+	SetShutdowner(shutdowner fx.Shutdowner) HttpBuilder
+	// AddListenHooks are called on start with the address the server is bound to, which is how the port is
+	// found when listening on port "0"
+	AddListenHooks(...func(addr net.Addr)) HttpBuilder
+	// AddPanicHooks are called when a panic is recovered, panics are replied as internal errors and logged with
+	// their stack to the logger
+	AddPanicHooks(...PanicHook) HttpBuilder
 	AddApiInterceptors(...gin.HandlerFunc) HttpBuilder
 	AddRouterInterceptors(...gin.HandlerFunc) HttpBuilder
 	AddSystemHandlers(...IHandler) HttpBuilder
+	// AddWebSocketHandlers registers MethodWebSocket handlers, unlike system handlers they run after the api
+	// interceptors. Open connections are closed when the server stops.
+	AddWebSocketHandlers(...IHandler) HttpBuilder
+	// SetReplyContentTypes sets the content types replies are negotiated to, the first one is used when the Accept
+	// header matches none of them. Replies are JSON only by default, see replyCodec.ContentTypes() for the others.
+	SetReplyContentTypes(contentTypes ...string) HttpBuilder
 	Build(lifecycle fx.Lifecycle) gin.IRouter
+	// SetDiscoveryServiceProvider registers the server on start, heartbeats and deregisters it on stop when the
+	// provider implements discoveryService.Heartbeater and discoveryService.Deregisterer
 	SetDiscoveryServiceProvider(dsp discoveryService.DiscoveryServiceProvider) HttpBuilder
+	// SetRegistrationBackoff sets the first and max wait between failed registration attempts, the wait doubles
+	// after every failure
+	SetRegistrationBackoff(initial, max time.Duration) HttpBuilder
+	// SetTLS serves HTTPS, the verified client certificate of a request is available to interceptors and handlers
+	// with http.PeerIdentityFromContext
+	SetTLS(settings TLSSettings) HttpBuilder
+	// SetHTTP2 tunes HTTP/2 and lets the server accept it over cleartext (h2c)
+	SetHTTP2(settings HTTP2Settings) HttpBuilder
 }
@@ -0,0 +1,389 @@
+package http
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/orchestd/servicereply"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	BindingSourceJson   = "json"
+	BindingSourceQuery  = "query"
+	BindingSourceHeader = "header"
+	BindingSourceUri    = "uri"
+)
+
+// FieldError describes a single request field that couldn't be bound, it is returned to the caller in the
+// "fields" reply value of the bad request reply
+type FieldError struct {
+	Field  string      `json:"field"`
+	Source string      `json:"source"`
+	Rule   string      `json:"rule,omitempty"`
+	Param  string      `json:"param,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// bindRequest fills req from every part of the request. Sources are applied in the following order, so a later
+// source overrides a value set by an earlier one: json body, query (`form` tags), headers (`header` tags)
+// and path parameters (`uri` tags). The `default=` option of a query, header or uri tag only applies to fields no
+// earlier source set. The struct is validated once all sources were bound.
+func bindRequest(ginCtx *gin.Context, req interface{}) servicereply.ServiceReply {
+	// the paths of the fields set so far, only tracked for the types having defaults
+	var bound map[string]bool
+	if hasDefaults(reflect.TypeOf(req)) {
+		bound = make(map[string]bool)
+	}
+	if hasBody(ginCtx.Request) {
+		if err := bindJsonBody(ginCtx.Request, req, bound); err != nil {
+			return err
+		}
+	}
+	// the sources are bound when empty too for the types having defaults, so the defaults apply
+	if query := ginCtx.Request.URL.Query(); len(query) > 0 || bound != nil {
+		if err := bindValues(req, query, "form", BindingSourceQuery, "invalidQuery", bound); err != nil {
+			return err
+		}
+	}
+	if headers := headerValues(req, ginCtx.Request.Header); len(headers) > 0 || bound != nil {
+		if err := bindValues(req, headers, BindingSourceHeader, BindingSourceHeader, "invalidHeader", bound); err != nil {
+			return err
+		}
+	}
+	if len(ginCtx.Params) > 0 || bound != nil {
+		params := make(map[string][]string, len(ginCtx.Params))
+		for _, p := range ginCtx.Params {
+			params[p.Key] = []string{p.Value}
+		}
+		if err := bindValues(req, params, BindingSourceUri, BindingSourceUri, "invalidUri", bound); err != nil {
+			return err
+		}
+	}
+	return validateRequest(req)
+}
+
+// validateRequest runs the `binding` validation tags of req through gin's validator and lists every failing field
+// with its rule and offending value
+func validateRequest(req interface{}) servicereply.ServiceReply {
+	if binding.Validator == nil {
+		return nil
+	}
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return servicereply.NewBadRequestError("invalidRequest").WithError(err).WithLogMessage("Request struct validation failed")
+	}
+	t := reflect.TypeOf(req)
+	fieldErrs := make([]FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:  requestFieldName(t, fe.StructNamespace()),
+			Source: fieldSource(t, fe.StructNamespace()),
+			Rule:   fe.Tag(),
+			Param:  fe.Param(),
+			Value:  fe.Value(),
+		})
+	}
+	return bindingError("invalidRequest", err, fieldErrs...).WithLogMessage("Request struct validation failed")
+}
+
+func hasBody(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodDelete {
+		return false
+	}
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// bindJsonBody decodes the body into req, the fields it sets are added to bound when it isn't nil
+func bindJsonBody(r *http.Request, req interface{}, bound map[string]bool) servicereply.ServiceReply {
+	var body bytes.Buffer
+	decoder := stdjson.NewDecoder(r.Body)
+	if bound != nil {
+		decoder = stdjson.NewDecoder(io.TeeReader(r.Body, &body))
+	}
+	err := decoder.Decode(req)
+	if err == nil || errors.Is(err, io.EOF) {
+		var raw map[string]stdjson.RawMessage
+		if bound != nil && stdjson.Unmarshal(body.Bytes(), &raw) == nil {
+			jsonBound(reflect.ValueOf(req), raw, "", bound)
+		}
+		return nil
+	}
+	fieldErr := FieldError{Source: BindingSourceJson, Error: err.Error()}
+	var typeErr *stdjson.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		fieldErr.Field = typeErr.Field
+	}
+	return bindingError("invalidJson", err, fieldErr).WithLogMessage("Cannot parse request to struct")
+}
+
+// bindValues maps values into req by the given struct tag. When the mapping fails every value is mapped on its
+// own so the reply can point at the offending fields. With bound set, the fields an earlier source set keep their
+// value instead of getting the `default=` of the tag, and the fields values sets are added to it.
+func bindValues(req interface{}, values map[string][]string, tag, source, userMessage string, bound map[string]bool) servicereply.ServiceReply {
+	var restore []func()
+	if bound != nil {
+		keepBound(reflect.ValueOf(req), values, tag, "", bound, &restore)
+	}
+	err := binding.MapFormWithTag(req, values, tag)
+	for _, r := range restore {
+		r()
+	}
+	if err == nil {
+		return nil
+	}
+	var fieldErrs []FieldError
+	for key, val := range values {
+		if keyErr := binding.MapFormWithTag(req, map[string][]string{key: val}, tag); keyErr != nil {
+			fieldErrs = append(fieldErrs, FieldError{Field: key, Source: source, Error: keyErr.Error()})
+		}
+	}
+	if len(fieldErrs) == 0 {
+		fieldErrs = append(fieldErrs, FieldError{Source: source, Error: err.Error()})
+	}
+	return bindingError(userMessage, err, fieldErrs...).WithLogMessage("Cannot bind " + source + " to request struct")
+}
+
+func bindingError(userMessage string, err error, fields ...FieldError) servicereply.ServiceReply {
+	return servicereply.NewBadRequestError(userMessage).WithError(err).WithReplyValues(servicereply.ValuesMap{"fields": fields})
+}
+
+// headerKeysCache holds the `header` tag values of every request type that was bound
+var headerKeysCache sync.Map
+
+// headerValues collects the headers requested by `header` tags, keyed by the tag value. Header names are matched
+// case-insensitively.
+func headerValues(req interface{}, header http.Header) map[string][]string {
+	t := reflect.TypeOf(req)
+	keys, ok := headerKeysCache.Load(t)
+	if !ok {
+		keys, _ = headerKeysCache.LoadOrStore(t, collectHeaderKeys(t, nil))
+	}
+	values := make(map[string][]string)
+	for _, key := range keys.([]string) {
+		if v := header.Values(key); len(v) > 0 {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+func collectHeaderKeys(t reflect.Type, keys []string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return keys
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get(BindingSourceHeader)
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			// pointers aren't followed to avoid looping on recursive types
+			if field.Type.Kind() == reflect.Struct {
+				keys = collectHeaderKeys(field.Type, keys)
+			}
+			continue
+		}
+		keys = append(keys, strings.Split(tag, ",")[0])
+	}
+	return keys
+}
+
+// defaultsCache tells whether a request type has `default=` options in its query, header or uri tags
+var defaultsCache sync.Map
+
+func hasDefaults(t reflect.Type) bool {
+	found, ok := defaultsCache.Load(t)
+	if !ok {
+		found, _ = defaultsCache.LoadOrStore(t, collectDefaults(t, map[reflect.Type]bool{}))
+	}
+	return found.(bool)
+}
+
+func collectDefaults(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		for _, tag := range []string{"form", BindingSourceHeader, BindingSourceUri} {
+			if strings.Contains(field.Tag.Get(tag), "default=") {
+				return true
+			}
+		}
+		if collectDefaults(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonBound adds the paths of the fields of v set by the json object raw to bound, keys match the field names
+// case-insensitively like encoding/json
+func jsonBound(v reflect.Value, raw map[string]stdjson.RawMessage, path string, bound map[string]bool) {
+	v, ok := structValue(v)
+	if !ok {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldPath := path + "." + strconv.Itoa(i)
+		name := strings.Split(field.Tag.Get(BindingSourceJson), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			// embedded fields are set from the same object
+			jsonBound(v.Field(i), raw, fieldPath, bound)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		for key, value := range raw {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			bound[fieldPath] = true
+			var nested map[string]stdjson.RawMessage
+			if stdjson.Unmarshal(value, &nested) == nil {
+				jsonBound(v.Field(i), nested, fieldPath, bound)
+			}
+			break
+		}
+	}
+}
+
+// keepBound adds to restore the funcs setting back the bound fields of v that values doesn't set, so the `default=`
+// of their tag doesn't overwrite them. The fields values sets are added to bound.
+func keepBound(v reflect.Value, values map[string][]string, tag, path string, bound map[string]bool, restore *[]func()) {
+	v, ok := structValue(v)
+	if !ok {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fieldPath := path + "." + strconv.Itoa(i)
+		tagValue := field.Tag.Get(tag)
+		if tagValue == "-" {
+			continue
+		}
+		name := strings.Split(tagValue, ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		fieldValue := v.Field(i)
+		if _, ok := values[name]; ok {
+			bound[fieldPath] = true
+		} else if bound[fieldPath] && strings.Contains(tagValue, "default=") && fieldValue.CanSet() {
+			saved := reflect.New(field.Type).Elem()
+			saved.Set(fieldValue)
+			*restore = append(*restore, func() {
+				fieldValue.Set(saved)
+			})
+		}
+		keepBound(fieldValue, values, tag, fieldPath, bound, restore)
+	}
+}
+
+// structValue follows the pointers of v to a struct, ok is false when there is none
+func structValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+// tagSources lists the request struct tags in the order used to name a field back to the caller
+var tagSources = []struct{ tag, source string }{
+	{BindingSourceUri, BindingSourceUri},
+	{BindingSourceHeader, BindingSourceHeader},
+	{"form", BindingSourceQuery},
+	{BindingSourceJson, BindingSourceJson},
+}
+
+// requestFieldName translates a validator struct namespace (Req.Inner.Name) into the names the caller used,
+// according to the field's uri, header, form or json tag
+func requestFieldName(t reflect.Type, namespace string) string {
+	var names []string
+	walkNamespace(t, namespace, func(field reflect.StructField, index string) {
+		name := field.Name
+		for _, ts := range tagSources {
+			if tagName := strings.Split(field.Tag.Get(ts.tag), ",")[0]; tagName != "" && tagName != "-" {
+				name = tagName
+				break
+			}
+		}
+		names = append(names, name+index)
+	})
+	return strings.Join(names, ".")
+}
+
+// fieldSource returns the binding source of the top level field the namespace points at
+func fieldSource(t reflect.Type, namespace string) string {
+	source := ""
+	walkNamespace(t, namespace, func(field reflect.StructField, _ string) {
+		if source != "" {
+			return
+		}
+		source = BindingSourceJson
+		for _, ts := range tagSources {
+			if field.Tag.Get(ts.tag) != "" {
+				source = ts.source
+				return
+			}
+		}
+	})
+	return source
+}
+
+func walkNamespace(t reflect.Type, namespace string, visit func(field reflect.StructField, index string)) {
+	parts := strings.Split(namespace, ".")
+	for _, part := range parts[1:] {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		name, index := part, ""
+		if i := strings.Index(part, "["); i >= 0 {
+			name, index = part[:i], part[i:]
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			return
+		}
+		visit(field, index)
+		t = field.Type
+	}
+}
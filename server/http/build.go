@@ -7,6 +7,7 @@ import (
 	"github.com/orchestd/transport/discoveryService"
 	"github.com/orchestd/transport/server"
 	"go.uber.org/fx"
+	"net"
 	"time"
 )
 
@@ -18,8 +19,18 @@ type HttpServerSettings struct {
 	apiInterceptors          []gin.HandlerFunc
 	routerInterceptors       []gin.HandlerFunc
 	systemHandlers           []server.IHandler
+	webSocketHandlers        []server.IHandler
 	DiscoveryServiceProvider discoveryService.DiscoveryServiceProvider
 	Statics                  map[string]string
+	ReplyContentTypes        []string
+	DrainPeriod              *time.Duration
+	Shutdowner               fx.Shutdowner
+	RegistrationBackoff      *time.Duration
+	RegistrationMaxBackoff   *time.Duration
+	TLS                      *server.TLSSettings
+	HTTP2                    *server.HTTP2Settings
+	listenHooks              []func(addr net.Addr)
+	panicHooks               []server.PanicHook
 }
 
 type defaultHttpServerConfigBuilder struct {
@@ -57,6 +68,56 @@ func (d *defaultHttpServerConfigBuilder) SetReadTimeout(duration time.Duration)
 	})
 	return d
 }
+func (d *defaultHttpServerConfigBuilder) SetDrainPeriod(duration time.Duration) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.DrainPeriod = &duration
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) SetRegistrationBackoff(initial, max time.Duration) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.RegistrationBackoff = &initial
+		cfg.RegistrationMaxBackoff = &max
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) SetTLS(settings server.TLSSettings) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.TLS = &settings
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) SetHTTP2(settings server.HTTP2Settings) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.HTTP2 = &settings
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) SetShutdowner(shutdowner fx.Shutdowner) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.Shutdowner = shutdowner
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) AddPanicHooks(hooks ...server.PanicHook) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.panicHooks = append(cfg.panicHooks, hooks...)
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) AddListenHooks(hooks ...func(addr net.Addr)) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.listenHooks = append(cfg.listenHooks, hooks...)
+	})
+	return d
+}
+
 func (d *defaultHttpServerConfigBuilder) SetLogger(logger log.Logger) server.HttpBuilder {
 	d.ll.PushBack(func(cfg *HttpServerSettings) {
 		cfg.Logger = logger
@@ -85,6 +146,20 @@ func (d *defaultHttpServerConfigBuilder) AddSystemHandlers(handlers ...server.IH
 	return d
 }
 
+func (d *defaultHttpServerConfigBuilder) AddWebSocketHandlers(handlers ...server.IHandler) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.webSocketHandlers = append(cfg.webSocketHandlers, handlers...)
+	})
+	return d
+}
+
+func (d *defaultHttpServerConfigBuilder) SetReplyContentTypes(contentTypes ...string) server.HttpBuilder {
+	d.ll.PushBack(func(cfg *HttpServerSettings) {
+		cfg.ReplyContentTypes = contentTypes
+	})
+	return d
+}
+
 func (d *defaultHttpServerConfigBuilder) Build(lc fx.Lifecycle) gin.IRouter {
 	httpCfg := &HttpServerSettings{}
 	for e := d.ll.Front(); e != nil; e = e.Next() {
@@ -92,8 +167,7 @@ func (d *defaultHttpServerConfigBuilder) Build(lc fx.Lifecycle) gin.IRouter {
 		f(httpCfg)
 	}
 
-	return NewGinServer(httpCfg.DiscoveryServiceProvider, lc, httpCfg.Port, httpCfg.WriteTimeOut, httpCfg.ReadTimeOut,
-		httpCfg.Logger, httpCfg.apiInterceptors, httpCfg.routerInterceptors, httpCfg.systemHandlers, httpCfg.Statics)
+	return NewGinServerWithSettings(lc, httpCfg)
 }
 
 func (d *defaultHttpServerConfigBuilder) SetDiscoveryServiceProvider(ds discoveryService.DiscoveryServiceProvider) server.HttpBuilder {
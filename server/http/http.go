@@ -3,16 +3,21 @@ package http
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/orchestd/dependencybundler/interfaces/log"
 	"github.com/orchestd/servicereply"
-	httpError "github.com/orchestd/servicereply/http"
 	"github.com/orchestd/servicereply/status"
 	"github.com/orchestd/transport/discoveryService"
+	"github.com/orchestd/transport/replyTypes"
+	"github.com/orchestd/transport/requestId"
 	"github.com/orchestd/transport/server"
 	"go.uber.org/fx"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"html/template"
+	"net"
 	"net/http"
 	"reflect"
 	"time"
@@ -80,18 +85,9 @@ func (jr FileReplyTransportHooks) OnExecFail(c *gin.Context, err servicereply.Se
 func HandleFuncWithHook(mFunction interface{}, hooks transportHooks) func(context *gin.Context) {
 	return func(ginCtx *gin.Context) {
 		newH := createInnerHandlers(reflect.ValueOf(getHandlerRequestStruct(mFunction)))
-		if ginCtx.Request.Method != "GET" && ginCtx.Request.Method != "DELETE" {
-			if err := ginCtx.ShouldBindJSON(&newH); err != nil {
-				internalError := servicereply.NewBadRequestError("invalidJson").WithError(err).WithLogMessage("Cannot parse request to struct")
-				GinErrorReply(ginCtx, internalError, nil)
-				return
-			}
-		} else {
-			if err := ginCtx.ShouldBindQuery(newH); err != nil {
-				internalError := servicereply.NewBadRequestError("invalidQuery").WithError(err).WithLogMessage("Cannot parse query request to struct")
-				GinErrorReply(ginCtx, internalError, nil)
-				return
-			}
+		if err := bindRequest(ginCtx, newH); err != nil {
+			GinErrorReply(ginCtx, err, nil)
+			return
 		}
 		exec := func() (interface{}, servicereply.ServiceReply) {
 			c := reflect.ValueOf(ginCtx.Request.Context())
@@ -133,6 +129,30 @@ func FileReplyHandleFunc(mFunction interface{}, fileName string) func(context *g
 	return HandleFuncWithHook(mFunction, FileReplyTransportHooks{FileName: fileName})
 }
 
+// HandleWithHook is the compile time checked alternative to HandleFuncWithHook, no reflection is done per request
+func HandleWithHook[Req, Res any](mFunction func(context.Context, Req) (Res, servicereply.ServiceReply), hooks transportHooks) func(context *gin.Context) {
+	return func(ginCtx *gin.Context) {
+		var req Req
+		if err := bindRequest(ginCtx, &req); err != nil {
+			GinErrorReply(ginCtx, err, nil)
+			return
+		}
+		if response, err := mFunction(ginCtx.Request.Context(), req); err != nil {
+			hooks.OnExecFail(ginCtx, err, response)
+		} else {
+			hooks.OnExecSuccess(ginCtx, response)
+		}
+	}
+}
+
+func Handle[Req, Res any](mFunction func(context.Context, Req) (Res, servicereply.ServiceReply)) func(context *gin.Context) {
+	return HandleWithHook(mFunction, JsonReplyTransportHooks{})
+}
+
+func FileReplyHandle[Req, Res any](mFunction func(context.Context, Req) (Res, servicereply.ServiceReply), fileName string) func(context *gin.Context) {
+	return HandleWithHook(mFunction, FileReplyTransportHooks{FileName: fileName})
+}
+
 func createInnerHandlers(v reflect.Value) interface{} {
 	if v.Type().Kind() == reflect.Ptr {
 		v = v.Elem()
@@ -146,7 +166,13 @@ func IsFunc(v interface{}) bool {
 }
 
 func GinErrorReply(c *gin.Context, err servicereply.ServiceReply, res interface{}) {
-	statuserr := status.GetStatus(err.GetErrorType())
+	writeReply(c, replyTypes.GetHttpCode(err.GetErrorType()), errorResponse(c, err, res))
+}
+
+// errorResponse records err on the gin context (status, user message and HttpLog) and builds its reply envelope
+func errorResponse(c *gin.Context, err servicereply.ServiceReply, res interface{}) servicereply.Response {
+	setReplyType(c, err.GetErrorType())
+	statuserr := replyTypes.GetStatus(err.GetErrorType())
 	if statuserr != status.SuccessStatus {
 		statusCtx := context.WithValue(c.Request.Context(), "status", statuserr)
 		c.Request = c.Request.WithContext(statusCtx)
@@ -161,36 +187,46 @@ func GinErrorReply(c *gin.Context, err servicereply.ServiceReply, res interface{
 		LogMessage: err.GetLogMessage(),
 		LogValues:  err.GetLogValues(),
 	}
+	httpLogVal.RequestId, _ = requestId.FromContext(c.Request.Context())
 	c.Errors = append(c.Errors, &gin.Error{Err: err.GetError(), Type: gin.ErrorTypePrivate, Meta: httpLogVal})
 
-	Response := servicereply.Response{}
-	Response.Status = status.GetStatus(err.GetErrorType())
-
-	Response.Message = &servicereply.Message{
-		Id:     err.GetUserError(),
-		Values: err.GetReplyValues(),
-	}
-
 	if replyHeadersValues, ok := err.GetReplyValues()["replyHeadersValues"].(map[string]string); ok {
 		for key, val := range replyHeadersValues {
 			c.Header(key, val)
 		}
 		delete(err.GetReplyValues(), "replyHeadersValues")
 	}
+	return replyEnvelope(err, res)
+}
+
+func replyEnvelope(err servicereply.ServiceReply, res interface{}) servicereply.Response {
+	Response := servicereply.Response{}
+	Response.Status = replyTypes.GetStatus(err.GetErrorType())
+
+	Response.Message = &servicereply.Message{
+		Id:     err.GetUserError(),
+		Values: err.GetReplyValues(),
+	}
 
 	if err.IsSuccess() && res != nil {
 		Response.Data = res
 	}
-	c.JSON(httpError.GetHttpCode(err.GetErrorType()), Response)
+	return Response
 }
 
 func GinSuccessReply(c *gin.Context, reply interface{}) {
+	setReplyType(c, nil)
+	writeReply(c, http.StatusOK, successResponse(reply))
+}
+
+func successResponse(reply interface{}) servicereply.Response {
 	serviceReply := servicereply.Response{}
 	serviceReply.Status = status.SuccessStatus
 	serviceReply.Data = reply
-	c.JSON(http.StatusOK, serviceReply)
+	return serviceReply
 }
-func runHandler(router *gin.Engine, handler server.IHandler) {
+
+func runHandler(router *gin.Engine, handler server.IHandler) error {
 	switch handler.GetHttpType() {
 	case server.MethodPost:
 		router.POST(handler.GetMethod(), handler.GetHandler()...)
@@ -200,11 +236,28 @@ func runHandler(router *gin.Engine, handler server.IHandler) {
 		router.PUT(handler.GetMethod(), handler.GetHandler()...)
 	case server.MethodDelete:
 		router.DELETE(handler.GetMethod(), handler.GetHandler()...)
+	case server.MethodPatch:
+		router.PATCH(handler.GetMethod(), handler.GetHandler()...)
+	case server.MethodHead:
+		router.HEAD(handler.GetMethod(), handler.GetHandler()...)
+	case server.MethodOptions:
+		router.OPTIONS(handler.GetMethod(), handler.GetHandler()...)
+	default:
+		return fmt.Errorf("unsupported http type %q for system handler %s", handler.GetHttpType(), handler.GetMethod())
 	}
+	return nil
 }
 
+// InitializeGinRouter recovers the panics of the router before adding the interceptors and handlers, the panics
+// are written to gin.DefaultErrorWriter. Use Recovery on the engine to log them and alert.
 func InitializeGinRouter(router *gin.Engine, apiInterceptors, routerInterceptors []gin.HandlerFunc,
 	systemHandlers []server.IHandler, statics map[string]string) (gin.IRouter, error) {
+	router.Use(Recovery(nil))
+	return initializeGinRouter(router, apiInterceptors, routerInterceptors, systemHandlers, statics)
+}
+
+func initializeGinRouter(router *gin.Engine, apiInterceptors, routerInterceptors []gin.HandlerFunc,
+	systemHandlers []server.IHandler, statics map[string]string) (gin.IRouter, error) {
 
 	for k, v := range statics {
 		router.Static(k, v)
@@ -220,8 +273,6 @@ func InitializeGinRouter(router *gin.Engine, apiInterceptors, routerInterceptors
 
 	api := router.Group("/")
 
-	router.Use(gin.Recovery())
-
 	if len(apiInterceptors) > 0 {
 		for _, interceptor := range apiInterceptors {
 			if interceptor != nil {
@@ -232,7 +283,9 @@ func InitializeGinRouter(router *gin.Engine, apiInterceptors, routerInterceptors
 
 	if len(systemHandlers) > 0 {
 		for _, h := range systemHandlers {
-			runHandler(router, h)
+			if err := runHandler(router, h); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -247,25 +300,87 @@ const (
 func NewGinServer(dsp discoveryService.DiscoveryServiceProvider, lc fx.Lifecycle, port *string, readTimeout,
 	WriteTimeout *time.Duration, logger log.Logger, apiInterceptors []gin.HandlerFunc, routerInterceptors []gin.HandlerFunc,
 	systemHandlers []server.IHandler, statics map[string]string) gin.IRouter {
+	return NewGinServerWithSettings(lc, &HttpServerSettings{
+		Port:                     port,
+		WriteTimeOut:             WriteTimeout,
+		ReadTimeOut:              readTimeout,
+		Logger:                   logger,
+		apiInterceptors:          apiInterceptors,
+		routerInterceptors:       routerInterceptors,
+		systemHandlers:           systemHandlers,
+		DiscoveryServiceProvider: dsp,
+		Statics:                  statics,
+	})
+}
+
+func NewGinServerWithSettings(lc fx.Lifecycle, settings *HttpServerSettings) gin.IRouter {
+	dsp, logger := settings.DiscoveryServiceProvider, settings.Logger
+	port := settings.Port
 	if port == nil {
 		p := defaultPort
 		port = &p
 	}
+	readTimeout := settings.ReadTimeOut
 	if readTimeout == nil {
 		t := defaultTimeout
 		readTimeout = &t
 	}
+	WriteTimeout := settings.WriteTimeOut
 	if WriteTimeout == nil {
 		t := defaultTimeout
 		WriteTimeout = &t
 	}
 	router := gin.New()
-	h, _ := InitializeGinRouter(router, apiInterceptors, routerInterceptors, systemHandlers, statics)
+	router.Use(Recovery(logger, settings.panicHooks...))
+	state := newServerState()
+	router.Use(state.interceptor())
+	replyContentTypes, routerErr := normalizeReplyContentTypes(settings.ReplyContentTypes)
+	if routerErr == nil {
+		router.Use(replyContentTypesInterceptor(replyContentTypes))
+	}
+	webSockets := newWebSocketRegistry()
+	router.Use(webSockets.interceptor())
+	var tlsConfig *tlsReloader
+	if settings.TLS != nil {
+		tlsConfig = newTLSReloader(*settings.TLS, logger)
+		router.Use(peerIdentityInterceptor)
+	}
+	h, err := initializeGinRouter(router, settings.apiInterceptors, settings.routerInterceptors, settings.systemHandlers, settings.Statics)
+	if err == nil {
+		err = registerWebSocketHandlers(h, settings.webSocketHandlers)
+	}
+	if err != nil {
+		routerErr = err
+	}
+	if routerErr != nil {
+		// keep handing out a usable router so registrations don't panic, the error is returned from OnStart
+		h = router
+	}
+	// http.Server.Shutdown doesn't cancel the requests it waits for: sse streams are stopped once the drain period
+	// is over and the other requests are cancelled through the base context when the stop deadline passed
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	streamsCtx, stopStreams := context.WithCancel(context.Background())
+	baseCtx := withStreamsStop(requestsCtx, streamsCtx.Done())
 	s := &http.Server{
 		Addr:         ":" + *port, //appConf.ListenOnPort,
 		Handler:      router,
 		ReadTimeout:  *readTimeout,
 		WriteTimeout: *WriteTimeout, //getHttpRespTimeoutSeconds(appConf),
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+
+	registrar := newRegistration(dsp, logger, settings.RegistrationBackoff, settings.RegistrationMaxBackoff)
+	serve := s.Serve
+	if tlsConfig != nil {
+		s.TLSConfig = tlsConfig.serverConfig()
+		serve = func(ln net.Listener) error {
+			return s.ServeTLS(ln, "", "")
+		}
+	}
+	if settings.HTTP2 != nil && routerErr == nil {
+		routerErr = configureHTTP2(s, *settings.HTTP2, tlsConfig != nil)
 	}
 
 	lc.Append(fx.Hook{
@@ -274,21 +389,44 @@ func NewGinServer(dsp discoveryService.DiscoveryServiceProvider, lc fx.Lifecycle
 		// default, hooks have a total of 15 seconds to complete. Timeouts are
 		// passed via Go's usual context.Context.
 		OnStart: func(ctx context.Context) error {
-			// In production, we'd want to separate the Listen and Serve phases for
-			// better error-handling.
-			if logger != nil {
-				logger.Info(ctx, "HTTP service listening on port %s", *port)
+			if routerErr != nil {
+				return routerErr
 			}
-			go func() {
-				if sRep := dsp.Register(); !sRep.IsSuccess() {
-					panic(sRep.GetError()) //would a go func always be enough and fall after ListenAndServe ? should I just logErr ?
+			if tlsConfig != nil {
+				if err := tlsConfig.start(); err != nil {
+					return err
 				}
-			}()
+			}
+			ln, err := net.Listen("tcp", s.Addr)
+			if err != nil {
+				if tlsConfig != nil {
+					tlsConfig.stop()
+				}
+				return fmt.Errorf("cannot listen on %s: %w", s.Addr, err)
+			}
+			if logger != nil {
+				logger.Info(ctx, "HTTP service listening on %s", ln.Addr())
+			}
+			for _, hook := range settings.listenHooks {
+				hook(ln.Addr())
+			}
+			registrar.start()
 
 			go func() {
-				err := s.ListenAndServe()
-				if err != nil {
-					panic(err)
+				err := serve(ln)
+				if err == nil || errors.Is(err, http.ErrServerClosed) {
+					return
+				}
+				if logger != nil {
+					logger.WithError(err).Error(context.Background(), "HTTP service stopped serving")
+				} else {
+					fmt.Fprintf(gin.DefaultErrorWriter, "HTTP service stopped serving: %v\n", err)
+				}
+				if settings.Shutdowner == nil {
+					return
+				}
+				if err := settings.Shutdowner.Shutdown(); err != nil && logger != nil {
+					logger.WithError(err).Error(context.Background(), "Cannot shut the application down")
 				}
 			}()
 
@@ -298,17 +436,49 @@ func NewGinServer(dsp discoveryService.DiscoveryServiceProvider, lc fx.Lifecycle
 			if logger != nil {
 				logger.Info(ctx, "Shuting service down")
 			}
-			return s.Shutdown(ctx)
+			state.startDraining()
+			registrar.stop(ctx)
+			if settings.DrainPeriod != nil {
+				if logger != nil {
+					logger.Info(ctx, "Draining for %s", *settings.DrainPeriod)
+				}
+				drain(ctx, *settings.DrainPeriod)
+			}
+			if err := webSockets.closeAll(ctx); err != nil && logger != nil {
+				logger.Warn(ctx, "Not all websocket connections were closed: %v", err)
+			}
+			stopStreams()
+			err := s.Shutdown(ctx)
+			if err != nil {
+				if logger != nil {
+					state.logUnfinished(ctx, logger)
+				}
+				cancelRequests()
+			}
+			if tlsConfig != nil {
+				tlsConfig.stop()
+			}
+			return err
 		},
 	})
-	//if len(appConf.Domains) > 0 {
-	//	//domains := []string{"students-aid.org", "stud-aid.com"}
-	//	return certmagic.HTTPS(appConf.Domains, h.Engine)
-	//}
-
 	return h
 }
 
+// configureHTTP2 applies the settings to the HTTP/2 negotiated over TLS, and to the cleartext one when h2c is
+// enabled
+func configureHTTP2(s *http.Server, settings server.HTTP2Settings, withTLS bool) error {
+	h2s := &http2.Server{MaxConcurrentStreams: settings.MaxConcurrentStreams, IdleTimeout: settings.IdleTimeout}
+	if withTLS {
+		if err := http2.ConfigureServer(s, h2s); err != nil {
+			return fmt.Errorf("cannot configure HTTP/2: %w", err)
+		}
+	}
+	if settings.H2C {
+		s.Handler = h2c.NewHandler(s.Handler, h2s)
+	}
+	return nil
+}
+
 //func GinRedirectReply(c *gin.Context) {
 //	c.Redirect(http.StatusFound, c.RedirectUrl)
 //}
@@ -319,12 +489,19 @@ func getHandlerRequestStruct(f interface{}) interface{} {
 	return reflect.New(argType).Interface()
 }
 
+// IsAliveGinHandler replies 503 once the server started draining, so it can be used as the readiness probe
 func IsAliveGinHandler(c *gin.Context) {
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("charset", "utf-8")
 	c.Header("Access-Control-Allow-Headers", "token, x-requested-with")
 	c.Header("Access-Control-Allow-Methods", "PUT, GET, POST, DELETE, OPTIONS , PATCH")
 	c.Header("Access-Control-Allow-Credentials", "true")
+	if state, ok := c.Value(serverStateKey).(*serverState); ok && state.isDraining() {
+		c.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "draining",
+		})
+		return
+	}
 	c.JSON(200, map[string]interface{}{
 		"status": "success",
 	})
@@ -335,6 +512,8 @@ type HttpLog struct {
 	Action     string                 `json:"action"`
 	LogMessage *string                `json:"logMessage"`
 	LogValues  map[string]interface{} `json:"logValues"`
+	// RequestId is the id the request interceptor set on the request context
+	RequestId string `json:"requestId,omitempty"`
 }
 
 func (h HttpLog) GetSource() string {
@@ -353,6 +532,10 @@ func (h HttpLog) GetLogValues() map[string]interface{} {
 	return h.LogValues
 }
 
+func (h HttpLog) GetRequestId() string {
+	return h.RequestId
+}
+
 type IHttpLog interface {
 	GetSource() string
 	GetAction() string
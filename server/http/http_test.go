@@ -4,18 +4,53 @@ import (
 	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/replyCodec"
+	"github.com/orchestd/transport/server"
 	"github.com/smartystreets/goconvey/convey"
 	"go.uber.org/fx"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
+type testDiscoveryServiceProvider struct {
+}
+
+func (t testDiscoveryServiceProvider) Register() servicereply.ServiceReply {
+	return servicereply.NewNil()
+}
+
+// GetAddress resolves nothing, calls between services are tested with transportTest.Cluster
+func (t testDiscoveryServiceProvider) GetAddress(serviceName string) servicereply.ServiceReply {
+	return servicereply.NewNetworkError(fmt.Errorf("cant resolve host:%s", serviceName))
+}
+
+type testAddress struct {
+	addr net.Addr
+}
+
+func newTestAddress() *testAddress {
+	return &testAddress{}
+}
+
+func newTestRouter(lc fx.Lifecycle, address *testAddress) gin.IRouter {
+	return Builder().SetPort("0").SetDiscoveryServiceProvider(testDiscoveryServiceProvider{}).
+		AddListenHooks(func(addr net.Addr) {
+			address.addr = addr
+		}).Build(lc)
+}
+
 type TestInterface struct {
 }
 
@@ -30,45 +65,492 @@ type TestRes struct {
 	Hello string `json:"hello"`
 }
 
-func (i TestInterface) Test(c context.Context, req TestReq) (TestRes, ServiceReply) {
+func (i TestInterface) Test(c context.Context, req TestReq) (TestRes, servicereply.ServiceReply) {
 	return TestRes{Hello: "world"}, nil
 }
 
 func Test_Main(t *testing.T) {
-	testHandler := func(router *gin.Engine, m TestInterface) {
+	testHandler := func(router gin.IRouter, m TestInterface) {
 		router.GET("/", HandleFunc(m.Test))
 	}
 
+	var address *testAddress
+	app := fx.New(
+		fx.Provide(
+			newTestAddress,
+			newTestRouter,
+			NewTestInterface,
+		),
+		fx.Invoke(testHandler),
+		fx.Populate(&address),
+	)
+	startCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := app.Start(startCtx); err != nil {
+		log.Fatal(err)
+	}
+	defer app.Stop(startCtx)
+
 	convey.Convey("Given a test handler with empty request ", t, func() {
-		app := fx.New(
-			fx.Provide(
-				NewGinServer,
-				NewTestInterface,
-			),
-			fx.Invoke(testHandler),
-		)
-		startCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		defer cancel()
-		if err := app.Start(startCtx); err != nil {
-			log.Fatal(err)
-		}
 		convey.Convey("Get response from the handler ", func() {
-			resp, err := http.Get("http://localhost:8080/")
-			convey.Convey("http shouldn't return error", func() {
-				convey.So(err, convey.ShouldBeNil)
-			})
+			resp, err := http.Get("http://" + address.addr.String() + "/")
+			convey.So(err, convey.ShouldBeNil)
 			defer resp.Body.Close()
 			body, err := ioutil.ReadAll(resp.Body)
-			convey.Convey("body read shouldnt return error", func() {
-				convey.So(err, convey.ShouldBeNil)
-			})
+			convey.So(err, convey.ShouldBeNil)
 			var res TestRes
 			fmt.Println(string(body))
 			err = json.Unmarshal(body, &res)
-			convey.Convey("Error should be nil", func() {
-				convey.So(err, convey.ShouldBeNil)
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+
+	convey.Convey("Given a port that is already in use", t, func() {
+		_, port, _ := net.SplitHostPort(address.addr.String())
+		busy := fx.New(fx.Invoke(func(lc fx.Lifecycle) {
+			Builder().SetPort(port).SetDiscoveryServiceProvider(testDiscoveryServiceProvider{}).Build(lc)
+		}))
+		convey.So(busy.Start(startCtx), convey.ShouldNotBeNil)
+	})
+}
+
+func Test_SystemHandlers(t *testing.T) {
+	ok := func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	}
+
+	convey.Convey("Given system handlers for PATCH, HEAD and OPTIONS", t, func() {
+		router := gin.New()
+		_, err := InitializeGinRouter(router, nil, nil, []server.IHandler{
+			server.NewHttpHandler(server.MethodPatch, "/patch", ok)(),
+			server.NewHttpHandler(server.MethodHead, "/head", ok)(),
+			server.NewHttpHandler(server.MethodOptions, "/options", ok)(),
+		}, nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		for path, method := range map[string]string{"/patch": http.MethodPatch, "/head": http.MethodHead, "/options": http.MethodOptions} {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusNoContent)
+		}
+	})
+
+	convey.Convey("Given a system handler with an unknown http type", t, func() {
+		_, err := InitializeGinRouter(gin.New(), nil, nil, []server.IHandler{
+			server.NewHttpHandler("TRACE", "/trace", ok)(),
+		}, nil)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+type TypedReq struct {
+	Name string `json:"name" form:"name"`
+}
+
+func Test_Handle(t *testing.T) {
+	greet := func(c context.Context, req TypedReq) (TestRes, servicereply.ServiceReply) {
+		if req.Name == "" {
+			return TestRes{}, servicereply.NewBadRequestError("missingName")
+		}
+		return TestRes{Hello: req.Name}, nil
+	}
+	router := gin.New()
+	router.GET("/greet", Handle(greet))
+	router.POST("/greet", Handle(greet))
+
+	convey.Convey("Given a typed handler", t, func() {
+		convey.Convey("query args are bound on GET", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/greet?name=world", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(w.Body.String(), convey.ShouldEqual, `{"status":"success","data":{"hello":"world"}}`)
+		})
+		convey.Convey("json body is bound on POST", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{"name":"world"}`)))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+			convey.So(w.Body.String(), convey.ShouldEqual, `{"status":"success","data":{"hello":"world"}}`)
+		})
+		convey.Convey("a service reply error goes through GinErrorReply", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/greet", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(w.Body.String(), convey.ShouldContainSubstring, `"missingName"`)
+		})
+		convey.Convey("an invalid body is rejected", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{`)))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+			convey.So(w.Body.String(), convey.ShouldContainSubstring, `"invalidJson"`)
+		})
+	})
+}
+
+type BindReq struct {
+	Id     int    `uri:"id"`
+	Name   string `json:"name"`
+	Filter string `form:"filter"`
+	Caller string `header:"Caller"`
+}
+
+func Test_BindRequest(t *testing.T) {
+	echo := func(c context.Context, req BindReq) (BindReq, servicereply.ServiceReply) {
+		return req, nil
+	}
+	router := gin.New()
+	router.POST("/users/:id", Handle(echo))
+
+	convey.Convey("Given a handler on a route with path parameters", t, func() {
+		convey.Convey("uri, header, query and body are bound together", func() {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/users/7?filter=active", strings.NewReader(`{"name":"world"}`))
+			r.Header.Set("caller", "tests")
+			router.ServeHTTP(w, r)
+			convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+			var res struct {
+				Data BindReq `json:"data"`
+			}
+			convey.So(json.Unmarshal(w.Body.Bytes(), &res), convey.ShouldBeNil)
+			convey.So(res.Data, convey.ShouldResemble, BindReq{Id: 7, Name: "world", Filter: "active", Caller: "tests"})
+		})
+		convey.Convey("an empty body is allowed", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/7", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+		})
+		convey.Convey("a binding error names the failing field", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/abc", nil))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+			var res servicereply.Response
+			convey.So(json.Unmarshal(w.Body.Bytes(), &res), convey.ShouldBeNil)
+			convey.So(res.GetMessageId(), convey.ShouldEqual, "invalidUri")
+			fields := res.Message.Values["fields"].([]interface{})
+			convey.So(fields, convey.ShouldHaveLength, 1)
+			convey.So(fields[0].(map[string]interface{})["field"], convey.ShouldEqual, "id")
+			convey.So(fields[0].(map[string]interface{})["source"], convey.ShouldEqual, BindingSourceUri)
+		})
+	})
+}
+
+type PageReq struct {
+	Filter string `form:"filter"`
+	Page   struct {
+		Size  int `json:"size" form:"size,default=10"`
+		Token int `json:"token" form:"token,default=1"`
+	} `json:"page"`
+	Sort   string `json:"sort" form:"sort,default=name"`
+	Tenant string `json:"tenant" header:"X-Tenant,default=public"`
+}
+
+func Test_BindRequestDefaults(t *testing.T) {
+	echo := func(c context.Context, req PageReq) (PageReq, servicereply.ServiceReply) {
+		return req, nil
+	}
+	router := gin.New()
+	router.POST("/users", Handle(echo))
+	bind := func(target, body string) PageReq {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+		var res struct {
+			Data PageReq `json:"data"`
+		}
+		convey.So(json.Unmarshal(w.Body.Bytes(), &res), convey.ShouldBeNil)
+		return res.Data
+	}
+
+	convey.Convey("Given query tags with defaults", t, func() {
+		convey.Convey("they don't overwrite the fields set by the body", func() {
+			req := bind("/users?filter=active", `{"sort":"","page":{"size":50}}`)
+			convey.So(req.Filter, convey.ShouldEqual, "active")
+			convey.So(req.Sort, convey.ShouldEqual, "")
+			convey.So(req.Page.Size, convey.ShouldEqual, 50)
+			convey.So(req.Page.Token, convey.ShouldEqual, 1)
+		})
+		convey.Convey("they apply without a query", func() {
+			req := bind("/users", "")
+			convey.So(req.Sort, convey.ShouldEqual, "name")
+			convey.So(req.Page.Size, convey.ShouldEqual, 10)
+			convey.So(req.Page.Token, convey.ShouldEqual, 1)
+			convey.So(req.Tenant, convey.ShouldEqual, "public")
+		})
+		convey.Convey("the query overrides the body", func() {
+			req := bind("/users?sort=email&size=20", `{"sort":"id","page":{"size":50}}`)
+			convey.So(req.Sort, convey.ShouldEqual, "email")
+			convey.So(req.Page.Size, convey.ShouldEqual, 20)
+		})
+	})
+}
+
+type ValidatedReq struct {
+	Id    int    `uri:"id" binding:"min=1"`
+	Email string `json:"email" binding:"required,email"`
+}
+
+func Test_Validation(t *testing.T) {
+	echo := func(c context.Context, req ValidatedReq) (ValidatedReq, servicereply.ServiceReply) {
+		return req, nil
+	}
+	router := gin.New()
+	router.PUT("/users/:id", Handle(echo))
+
+	convey.Convey("Given a handler with validated request fields", t, func() {
+		convey.Convey("a valid request passes", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(`{"email":"a@b.com"}`)))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+		})
+		convey.Convey("every failing field is listed with its rule and value", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/users/0", strings.NewReader(`{"email":"nope"}`)))
+			convey.So(w.Code, convey.ShouldEqual, http.StatusBadRequest)
+			var res struct {
+				Message struct {
+					Id     string `json:"id"`
+					Values struct {
+						Fields []FieldError `json:"fields"`
+					} `json:"values"`
+				} `json:"message"`
+			}
+			convey.So(json.Unmarshal(w.Body.Bytes(), &res), convey.ShouldBeNil)
+			convey.So(res.Message.Id, convey.ShouldEqual, "invalidRequest")
+			convey.So(res.Message.Values.Fields, convey.ShouldResemble, []FieldError{
+				{Field: "id", Source: BindingSourceUri, Rule: "min", Param: "1", Value: float64(0)},
+				{Field: "email", Source: BindingSourceJson, Rule: "email", Value: "nope"},
 			})
 		})
 	})
+}
+
+func Test_ReplyContentNegotiation(t *testing.T) {
+	router := gin.New()
+	contentTypes, err := normalizeReplyContentTypes([]string{replyCodec.MIMEJSON, "text/xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.Use(replyContentTypesInterceptor(contentTypes))
+	router.GET("/", HandleFunc(NewTestInterface().Test))
+
+	convey.Convey("Given a handler restricted to json and xml replies", t, func() {
+		for accept, expected := range map[string]string{
+			"application/xml": replyCodec.MIMEXML,
+			"text/xml":        replyCodec.MIMEXML,
+			"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": replyCodec.MIMEXML,
+			"application/xml, application/json":                               replyCodec.MIMEJSON,
+			"application/x-msgpack":                                           replyCodec.MIMEJSON,
+			"":                                                                replyCodec.MIMEJSON,
+		} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", accept)
+			router.ServeHTTP(w, r)
+			convey.So(w.Header().Get("Content-Type"), convey.ShouldStartWith, expected)
+			var res servicereply.Response
+			convey.So(replyCodec.ForContentType(w.Header().Get("Content-Type")).Unmarshal(w.Body.Bytes(), &res), convey.ShouldBeNil)
+			convey.So(res.Data, convey.ShouldResemble, map[string]interface{}{"hello": "world"})
+		}
+	})
+}
+
+type TickEvent struct {
+	N int `json:"n"`
+}
+
+func Test_SSEHandleFunc(t *testing.T) {
+	ticks := func(c context.Context, req TypedReq, events chan<- TickEvent) servicereply.ServiceReply {
+		for i := 1; i <= 2; i++ {
+			select {
+			case events <- TickEvent{N: i}:
+			case <-c.Done():
+				return nil
+			}
+		}
+		if req.Name == "fail" {
+			return servicereply.NewRejectedReply("tooManyTicks")
+		}
+		if req.Name == "panic" {
+			panic("ticks exploded")
+		}
+		return nil
+	}
+	router := gin.New()
+	router.GET("/ticks", SSEHandleFunc(ticks, time.Second))
+
+	convey.Convey("Given an sse handler", t, func() {
+		convey.Convey("events are streamed and the stream ends with an end event", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ticks", nil))
+			convey.So(w.Header().Get("Content-Type"), convey.ShouldEqual, "text/event-stream")
+			convey.So(w.Body.String(), convey.ShouldEqual, "retry: 1000\n\n"+
+				"id: 1\ndata: {\"n\":1}\n\n"+
+				"id: 2\ndata: {\"n\":2}\n\n"+
+				"id: 3\nevent: end\ndata: {\"status\":\"success\"}\n\n")
+		})
+		convey.Convey("a returned error is sent as the final event", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ticks?name=fail", nil))
+			convey.So(w.Body.String(), convey.ShouldEndWith,
+				"id: 3\nevent: error\ndata: {\"status\":\"rejected\",\"message\":{\"id\":\"tooManyTicks\",\"values\":null}}\n\n")
+		})
+		convey.Convey("a panic is sent as an internal error event", func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ticks?name=panic", nil))
+			convey.So(w.Body.String(), convey.ShouldContainSubstring, "id: 3\nevent: error\ndata: {\"status\":\"error\"")
+		})
+		convey.Convey("the handler is cancelled when the client goes away", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ticks", nil).WithContext(ctx))
+			convey.So(w.Body.String(), convey.ShouldNotContainSubstring, "event: end")
+		})
+	})
+}
+
+func Test_WebSocketHandleFunc(t *testing.T) {
+	greet := func(c context.Context, req TypedReq) (TestRes, servicereply.ServiceReply) {
+		if req.Name == "" {
+			return TestRes{}, servicereply.NewBadRequestError("missingName")
+		}
+		if c.Value("token") != "secret" {
+			return TestRes{}, servicereply.NewServiceAuthError("noToken")
+		}
+		return TestRes{Hello: req.Name}, PushWebSocketReply(c, TestRes{Hello: "pushed"})
+	}
+	router := gin.New()
+	registry := newWebSocketRegistry()
+	router.Use(registry.interceptor())
+	api, err := InitializeGinRouter(router, []gin.HandlerFunc{func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), "token", c.GetHeader("token")))
+	}}, nil, nil, nil)
+	if err == nil {
+		err = registerWebSocketHandlers(api, []server.IHandler{server.NewWebSocketHandler("/ws", WebSocketHandleFunc(greet))()})
+	}
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	convey.Convey("Given a websocket handler behind an api interceptor", t, func() {
+		convey.So(err, convey.ShouldBeNil)
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", http.Header{"Token": {"secret"}})
+		convey.So(err, convey.ShouldBeNil)
+		defer conn.Close()
+
+		var res servicereply.Response
+		convey.So(conn.WriteMessage(websocket.TextMessage, []byte(`{"name":"world"}`)), convey.ShouldBeNil)
+		convey.So(conn.ReadJSON(&res), convey.ShouldBeNil)
+		convey.So(res.Data, convey.ShouldResemble, map[string]interface{}{"hello": "pushed"})
+		convey.So(conn.ReadJSON(&res), convey.ShouldBeNil)
+		convey.So(res.Data, convey.ShouldResemble, map[string]interface{}{"hello": "world"})
+
+		res = servicereply.Response{}
+		convey.So(conn.WriteMessage(websocket.TextMessage, []byte(`{}`)), convey.ShouldBeNil)
+		convey.So(conn.ReadJSON(&res), convey.ShouldBeNil)
+		convey.So(res.GetMessageId(), convey.ShouldEqual, "missingName")
+
+		convey.So(registry.closeAll(context.Background()), convey.ShouldBeNil)
+		_, _, err = conn.ReadMessage()
+		convey.So(websocket.IsCloseError(err, websocket.CloseGoingAway), convey.ShouldBeTrue)
+	})
+}
+
+func Test_Draining(t *testing.T) {
+	state := newServerState()
+	router := gin.New()
+	router.Use(state.interceptor())
+	router.GET("/isAlive", IsAliveGinHandler)
+
+	convey.Convey("Given a server state", t, func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/isAlive", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusOK)
+
+		state.startDraining()
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/isAlive", nil))
+		convey.So(w.Code, convey.ShouldEqual, http.StatusServiceUnavailable)
+
+		inFlight := 0
+		state.inFlight.Range(func(_, _ interface{}) bool {
+			inFlight++
+			return true
+		})
+		convey.So(inFlight, convey.ShouldEqual, 0)
+	})
+}
+
+type flakyDiscoveryServiceProvider struct {
+	testDiscoveryServiceProvider
+	mu           sync.Mutex
+	failures     int
+	registered   int
+	heartbeats   int
+	deregistered bool
+}
+
+func (f *flakyDiscoveryServiceProvider) Register() servicereply.ServiceReply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return servicereply.NewIoError(fmt.Errorf("registry unavailable"))
+	}
+	f.registered++
+	return servicereply.NewNil()
+}
+
+func (f *flakyDiscoveryServiceProvider) Heartbeat() servicereply.ServiceReply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.heartbeats++
+	if f.heartbeats == 2 {
+		return servicereply.NewIoError(fmt.Errorf("registration expired"))
+	}
+	return servicereply.NewNil()
+}
+
+func (f *flakyDiscoveryServiceProvider) HeartbeatInterval() time.Duration {
+	return 5 * time.Millisecond
+}
+
+func (f *flakyDiscoveryServiceProvider) Deregister() servicereply.ServiceReply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deregistered = true
+	return servicereply.NewNil()
+}
+
+func Test_Registration(t *testing.T) {
+	convey.Convey("Given a discovery service that fails the first registrations", t, func() {
+		dsp := &flakyDiscoveryServiceProvider{failures: 2}
+		backoff := time.Millisecond
+		r := newRegistration(dsp, nil, &backoff, &backoff)
+		r.start()
+		// registered twice once the heartbeat failed, then heartbeating again
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			dsp.mu.Lock()
+			renewed := dsp.registered == 2 && dsp.heartbeats > 2
+			dsp.mu.Unlock()
+			if renewed {
+				break
+			}
+		}
+		r.stop(context.Background())
+
+		dsp.mu.Lock()
+		defer dsp.mu.Unlock()
+		convey.So(dsp.failures, convey.ShouldEqual, 0)
+		convey.So(dsp.registered, convey.ShouldEqual, 2)
+		convey.So(dsp.heartbeats, convey.ShouldBeGreaterThan, 2)
+		convey.So(dsp.deregistered, convey.ShouldBeTrue)
+	})
 
+	convey.Convey("Given a backoff reaching its max", t, func() {
+		backoff := time.Second
+		for i := 0; i < 100; i++ {
+			backoff = nextBackoff(backoff, 3*time.Second)
+			convey.So(backoff, convey.ShouldBeLessThanOrEqualTo, 3*time.Second)
+		}
+		convey.So(backoff, convey.ShouldEqual, 3*time.Second)
+	})
 }
@@ -0,0 +1,161 @@
+package accessLog
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/requestId"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Redacted replaces the values of sensitive headers and fields
+	Redacted = "[REDACTED]"
+
+	// callerHeader is set by the client interceptor contextValuesToHeaders.ServiceNameToHeader
+	callerHeader = "Caller"
+)
+
+var (
+	defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Token", "X-Api-Key"}
+	defaultRedactedFields  = []string{"password", "token", "secret", "authorization", "apiKey"}
+)
+
+// Settings of the access log, all optional
+type Settings struct {
+	// Headers logs the request headers
+	Headers bool
+	// RedactedHeaders are added to the headers whose values are never logged: Authorization, Proxy-Authorization,
+	// Cookie, Set-Cookie, Token and X-Api-Key
+	RedactedHeaders []string
+	// RedactedFields are added to the HttpLog values whose values are never logged, at any depth: password, token,
+	// secret, authorization and apiKey. Names are case insensitive.
+	RedactedFields []string
+	// SkipPaths are registered paths that aren't logged, e.g. the readiness probe
+	SkipPaths []string
+}
+
+// AccessLog is a router interceptor logging a line per request once it was served. Failed requests are logged with
+// the HttpLog GinErrorReply recorded, at error level for server errors and warning level for the others. Lines
+// have the request id when the requestId interceptor is added before this one.
+func AccessLog(logger log.Logger, settings Settings) gin.HandlerFunc {
+	redactedHeaders := map[string]bool{}
+	for _, header := range append(defaultRedactedHeaders, settings.RedactedHeaders...) {
+		redactedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+	redactedFields := map[string]bool{}
+	for _, field := range append(defaultRedactedFields, settings.RedactedFields...) {
+		redactedFields[strings.ToLower(field)] = true
+	}
+	skip := map[string]bool{}
+	for _, path := range settings.SkipPaths {
+		skip[path] = true
+	}
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		panicking := true
+		defer func() {
+			logRequest(c, logger, settings, redactedHeaders, redactedFields, start, panicking)
+		}()
+		c.Next()
+		panicking = false
+	}
+}
+
+// logRequest writes the line of a request, it runs while a panic goes through the interceptor so panicking requests are
+// logged with the internal error Recovery replies
+func logRequest(c *gin.Context, logger log.Logger, settings Settings, redactedHeaders, redactedFields map[string]bool,
+	start time.Time, panicking bool) {
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	code, et, replyStatus, replied := serverHttp.ServedReply(c, panicking)
+	fields := map[string]interface{}{
+		"method":    c.Request.Method,
+		"route":     route,
+		"path":      c.Request.URL.Path,
+		"status":    code,
+		"latencyMs": float64(time.Since(start).Microseconds()) / 1000,
+		"clientIp":  c.ClientIP(),
+	}
+	if id, ok := requestId.FromContext(c.Request.Context()); ok {
+		fields["requestId"] = id
+	}
+	if caller := c.GetHeader(callerHeader); caller != "" {
+		fields["caller"] = caller
+	}
+	if replied {
+		fields["replyType"], fields["replyStatus"] = string(et), string(replyStatus)
+	}
+	if panicking {
+		fields["panic"] = true
+	}
+	if settings.Headers {
+		headers := make(map[string]string, len(c.Request.Header))
+		for name, values := range c.Request.Header {
+			if redactedHeaders[name] {
+				headers[name] = Redacted
+			} else {
+				headers[name] = strings.Join(values, ", ")
+			}
+		}
+		fields["headers"] = headers
+	}
+
+	var err error
+	if last := c.Errors.Last(); last != nil {
+		err = last.Err
+		if httpLog, ok := last.Meta.(serverHttp.IHttpLog); ok {
+			fields["source"], fields["action"] = httpLog.GetSource(), httpLog.GetAction()
+			if message := httpLog.GetLogMessage(); message != nil {
+				fields["logMessage"] = *message
+			}
+			if values := httpLog.GetLogValues(); len(values) > 0 {
+				fields["logValues"] = redact(values, redactedFields)
+			}
+		}
+	}
+
+	entry := logger.WithFields(fields)
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+	ctx := c.Request.Context()
+	switch {
+	case code >= http.StatusInternalServerError:
+		entry.Error(ctx, "%s %s %d", c.Request.Method, route, code)
+	case code >= http.StatusBadRequest || err != nil:
+		entry.Warn(ctx, "%s %s %d", c.Request.Method, route, code)
+	default:
+		entry.Info(ctx, "%s %s %d", c.Request.Method, route, code)
+	}
+}
+
+// redact copies values replacing the redacted fields, the HttpLog values are never modified
+func redact(values map[string]interface{}, redactedFields map[string]bool) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if redactedFields[strings.ToLower(key)] {
+			redacted[key] = Redacted
+			continue
+		}
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			redacted[key] = redact(nested, redactedFields)
+		case servicereply.ValuesMap:
+			redacted[key] = redact(nested, redactedFields)
+		default:
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,85 @@
+package accessLog_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	logDep "github.com/orchestd/log"
+	"github.com/orchestd/servicereply"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/client/http/interceptors/contextValuesToHeaders"
+	"github.com/orchestd/transport/server"
+	"github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/server/http/interceptors/accessLog"
+	"github.com/orchestd/transport/server/http/interceptors/requestId"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func Test_AccessLog(t *testing.T) {
+	logger := transportTest.NewLogger()
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name: "users",
+		Builder: func() server.HttpBuilder {
+			return http.Builder().AddRouterInterceptors(requestId.RequestId(), accessLog.AccessLog(logger, accessLog.Settings{Headers: true,
+				RedactedHeaders: []string{"X-Session"}, RedactedFields: []string{"card"}, SkipPaths: []string{"/health"}}))
+		},
+		Routes: func(router gin.IRouter) {
+			router.GET("/users/:id", func(c *gin.Context) {
+				http.GinSuccessReply(c, nil)
+			})
+			router.GET("/health", func(c *gin.Context) {
+				http.GinSuccessReply(c, nil)
+			})
+			router.POST("/login", http.Handle(func(ctx context.Context, req struct{}) (interface{}, servicereply.ServiceReply) {
+				return nil, servicereply.NewInternalServiceError(errors.New("db down")).WithLogMessage("cannot login").
+					WithLogValues(servicereply.ValuesMap{"user": "bob", "password": "hunter2",
+						"payment": map[string]interface{}{"Card": "4111", "currency": "EUR"}})
+			}))
+		},
+	})
+	httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+		AddInterceptors(contextValuesToHeaders.ServiceNameToHeader("orders")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := map[string]string{"Authorization": "Bearer abc", "X-Session": "s1", "X-Tenant": "acme"}
+	httpClient.Get(context.Background(), "users", "users/1", nil, headers)
+	httpClient.Get(context.Background(), "users", "health", nil, nil)
+	httpClient.Post(context.Background(), nil, "users", "login", nil, headers)
+
+	convey.Convey("Given requests to a logged service", t, func() {
+		convey.Convey("successful requests are logged at info level", func() {
+			entries := logger.Find(logDep.InfoLevel)
+			convey.So(entries, convey.ShouldHaveLength, 1)
+			fields := entries[0].Fields
+			convey.So(entries[0].Message, convey.ShouldEqual, "GET /users/:id 200")
+			convey.So(fields["route"], convey.ShouldEqual, "/users/:id")
+			convey.So(fields["path"], convey.ShouldEqual, "/users/1")
+			convey.So(fields["status"], convey.ShouldEqual, 200)
+			convey.So(fields["caller"], convey.ShouldEqual, "orders")
+			convey.So(fields["requestId"], convey.ShouldNotBeEmpty)
+			convey.So(fields["replyStatus"], convey.ShouldEqual, "success")
+			convey.So(fields["latencyMs"], convey.ShouldBeGreaterThanOrEqualTo, 0)
+			convey.So(fields["headers"], convey.ShouldContainKey, "Authorization")
+			redactedHeaders := fields["headers"].(map[string]string)
+			convey.So(redactedHeaders["Authorization"], convey.ShouldEqual, accessLog.Redacted)
+			convey.So(redactedHeaders["X-Session"], convey.ShouldEqual, accessLog.Redacted)
+			convey.So(redactedHeaders["X-Tenant"], convey.ShouldEqual, "acme")
+		})
+
+		convey.Convey("failures are logged with their HttpLog", func() {
+			entries := logger.Find(logDep.ErrorLevel)
+			convey.So(entries, convey.ShouldHaveLength, 1)
+			fields := entries[0].Fields
+			convey.So(entries[0].Err, convey.ShouldBeError, "db down")
+			convey.So(fields["status"], convey.ShouldEqual, 500)
+			convey.So(fields["replyType"], convey.ShouldEqual, "internalServiceError")
+			convey.So(fields["source"], convey.ShouldNotBeEmpty)
+			convey.So(fields["logMessage"], convey.ShouldEqual, "cannot login")
+			convey.So(fields["logValues"], convey.ShouldResemble, map[string]interface{}{"user": "bob",
+				"password": accessLog.Redacted, "payment": map[string]interface{}{"Card": accessLog.Redacted, "currency": "EUR"}})
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	transportMetrics "github.com/orchestd/transport/metrics"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"strconv"
+	"time"
+)
+
+// Metrics is a router interceptor observing the duration of every request and the requests in flight. Requests
+// are labelled with their registered path, "unmatched" when no route matched, so ids in paths don't multiply the
+// series. Panicking requests are observed with the internal error Recovery replies.
+func Metrics(m *transportMetrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		inFlight := m.ServerInFlight.WithLabelValues(route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+		panicking := true
+		defer func() {
+			code, _, replyStatus, _ := serverHttp.ServedReply(c, panicking)
+			m.ServerDuration.WithLabelValues(route, c.Request.Method, strconv.Itoa(code), string(replyStatus)).
+				Observe(time.Since(start).Seconds())
+		}()
+		c.Next()
+		panicking = false
+	}
+}
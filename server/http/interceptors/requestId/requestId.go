@@ -0,0 +1,22 @@
+package requestId
+
+import (
+	"github.com/gin-gonic/gin"
+	transportRequestId "github.com/orchestd/transport/requestId"
+)
+
+// RequestId is a router interceptor giving every request an id, the one sent by the caller in the X-Request-ID
+// header or a new one. The id is set on the request context, where the client interceptor forwards it from and
+// GinErrorReply adds it to the HttpLog, and is echoed in the response. Add it before the interceptors logging
+// requests.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(transportRequestId.Header)
+		if !transportRequestId.IsValid(id) {
+			id = transportRequestId.New()
+		}
+		c.Request = c.Request.WithContext(transportRequestId.WithRequestId(c.Request.Context(), id))
+		c.Header(transportRequestId.Header, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply/status"
+	serverHttp "github.com/orchestd/transport/server/http"
+	transportTracing "github.com/orchestd/transport/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is a router interceptor continuing the trace of the caller, every request gets a server span named
+// after its method and registered path and tagged with the type and status of its reply
+func Tracing(tp trace.TracerProvider) gin.HandlerFunc {
+	tracer := tp.Tracer(transportTracing.InstrumentationName)
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := transportTracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", c.FullPath(), c.Request)...))
+		c.Request = c.Request.WithContext(ctx)
+		panicking := true
+		defer func() {
+			end(c, span, panicking)
+		}()
+		c.Next()
+		panicking = false
+	}
+}
+
+// end tags the span with the reply of the request and ends it, panicking requests get the internal error Recovery
+// replies
+func end(c *gin.Context, span trace.Span, panicking bool) {
+	defer span.End()
+	code, et, replyStatus, ok := serverHttp.ServedReply(c, panicking)
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(code)...)
+	spanStatus, message := semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(code, trace.SpanKindServer)
+	if ok {
+		span.SetAttributes(transportTracing.ReplyStatusKey.String(string(replyStatus)),
+			transportTracing.ReplyTypeKey.String(string(et)))
+		if replyStatus == status.ErrorStatus {
+			spanStatus, message = codes.Error, string(et)
+		}
+	}
+	if err := c.Errors.Last(); err != nil && spanStatus == codes.Error {
+		span.RecordError(err.Err)
+	}
+	span.SetStatus(spanStatus, message)
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/server"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+// Recovery replies the panics of the next handlers as internal errors with the servicereply.Response envelope,
+// logs them with their stack and calls the hooks. It must be the first handler of the engine to recover the panics
+// of the interceptors too. Panics are written to gin.DefaultErrorWriter when logger is nil.
+func Recovery(logger log.Logger, hooks ...server.PanicHook) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// the net/http sentinel to abort a response stays a panic
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			stack := debug.Stack()
+			if isBrokenPipe(recovered) {
+				// the client is gone, there is no one to reply to
+				if logger != nil {
+					logger.WithField("path", c.Request.URL.Path).Warn(c.Request.Context(), "Connection lost while serving: %v", recovered)
+				}
+				c.Abort()
+				return
+			}
+			route := c.FullPath()
+			if logger != nil {
+				logger.WithFields(map[string]interface{}{
+					"method": c.Request.Method,
+					"route":  route,
+					"path":   c.Request.URL.Path,
+					"stack":  string(stack),
+				}).Error(c.Request.Context(), "Recovered from panic: %v", recovered)
+			} else {
+				fmt.Fprintf(gin.DefaultErrorWriter, "[Recovery] panic serving %s %s: %v\n%s", c.Request.Method,
+					c.Request.URL.Path, recovered, stack)
+			}
+			for _, hook := range hooks {
+				hook(c, recovered, stack)
+			}
+			if !c.Writer.Written() {
+				GinErrorReply(c, servicereply.NewInternalServiceError(fmt.Errorf("panic: %v", recovered)).
+					WithLogMessage(fmt.Sprintf("recovered from panic serving %s %s", c.Request.Method, route)), nil)
+			}
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+func isBrokenPipe(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if !errors.As(err, new(*net.OpError)) || !errors.As(err, &syscallErr) {
+		return false
+	}
+	message := strings.ToLower(syscallErr.Error())
+	return strings.Contains(message, "broken pipe") || strings.Contains(message, "connection reset by peer")
+}
@@ -0,0 +1,94 @@
+package http_test
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	logDep "github.com/orchestd/log"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/servicereply/status"
+	"github.com/orchestd/transport/server"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/server/http/interceptors/accessLog"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func Test_Recovery(t *testing.T) {
+	logger := transportTest.NewLogger()
+	var mu sync.Mutex
+	var recovered []interface{}
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name: "users",
+		Builder: func() server.HttpBuilder {
+			return serverHttp.Builder().SetLogger(logger).AddRouterInterceptors(accessLog.AccessLog(logger, accessLog.Settings{}),
+				func(c *gin.Context) {
+					if c.GetHeader("X-Panic") != "" {
+						panic("interceptor failed")
+					}
+					c.Next()
+				}).AddPanicHooks(func(c *gin.Context, r interface{}, stack []byte) {
+				mu.Lock()
+				defer mu.Unlock()
+				recovered = append(recovered, r)
+			})
+		},
+		Routes: func(router gin.IRouter) {
+			router.GET("/getUser", func(c *gin.Context) {
+				var user map[string]string
+				c.JSON(http.StatusOK, user["name"][1:])
+			})
+		},
+	})
+	get := func(header string) (int, servicereply.Response) {
+		req, _ := http.NewRequest(http.MethodGet, cluster.Addresses("users")[0]+"/getUser", nil)
+		if header != "" {
+			req.Header.Set("X-Panic", header)
+		}
+		res, err := http.DefaultClient.Do(req)
+		convey.So(err, convey.ShouldBeNil)
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		var reply servicereply.Response
+		convey.So(json.Unmarshal(body, &reply), convey.ShouldBeNil)
+		return res.StatusCode, reply
+	}
+
+	convey.Convey("Given a handler panicking", t, func() {
+		code, reply := get("")
+		convey.So(code, convey.ShouldEqual, http.StatusInternalServerError)
+		convey.So(reply.Status, convey.ShouldEqual, status.ErrorStatus)
+
+		entries := logger.Find(logDep.ErrorLevel)
+		convey.So(entries, convey.ShouldNotBeEmpty)
+		last := entries[len(entries)-1]
+		convey.So(last.Message, convey.ShouldContainSubstring, "slice bounds out of range")
+		convey.So(last.Fields["route"], convey.ShouldEqual, "/getUser")
+		convey.So(last.Fields["stack"], convey.ShouldContainSubstring, "recovery_test.go")
+
+		convey.Convey("it is in the access log", func() {
+			var logged []transportTest.LogEntry
+			for _, entry := range entries {
+				if entry.Message == "GET /getUser 500" {
+					logged = append(logged, entry)
+				}
+			}
+			convey.So(logged, convey.ShouldNotBeEmpty)
+			convey.So(logged[0].Fields["panic"], convey.ShouldEqual, true)
+			convey.So(logged[0].Fields["replyType"], convey.ShouldEqual, "internalServiceError")
+		})
+	})
+
+	convey.Convey("Given a router interceptor panicking", t, func() {
+		code, reply := get("yes")
+		convey.So(code, convey.ShouldEqual, http.StatusInternalServerError)
+		convey.So(reply.Status, convey.ShouldEqual, status.ErrorStatus)
+
+		mu.Lock()
+		defer mu.Unlock()
+		convey.So(recovered, convey.ShouldContain, "interceptor failed")
+	})
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"github.com/orchestd/transport/discoveryService"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRegistrationBackoff    = 500 * time.Millisecond
+	defaultRegistrationMaxBackoff = 30 * time.Second
+)
+
+// registration keeps the server registered in the discovery service: Register is retried with an exponential
+// backoff, heartbeats are sent when the provider supports them and the instance is deregistered on stop
+type registration struct {
+	dsp        discoveryService.DiscoveryServiceProvider
+	logger     log.Logger
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu         sync.Mutex
+	registered bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func newRegistration(dsp discoveryService.DiscoveryServiceProvider, logger log.Logger, backoff, maxBackoff *time.Duration) *registration {
+	r := &registration{dsp: dsp, logger: logger, backoff: defaultRegistrationBackoff, maxBackoff: defaultRegistrationMaxBackoff}
+	if backoff != nil {
+		r.backoff = *backoff
+	}
+	if maxBackoff != nil {
+		r.maxBackoff = *maxBackoff
+	}
+	return r
+}
+
+func (r *registration) start() {
+	if r.dsp == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		for ctx.Err() == nil {
+			if !r.register(ctx) {
+				return
+			}
+			r.heartbeat(ctx)
+		}
+	}()
+}
+
+// stop ends the registration loop and deregisters the instance when it was registered
+func (r *registration) stop(ctx context.Context) {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+	r.mu.Lock()
+	registered := r.registered
+	r.mu.Unlock()
+	if !registered {
+		return
+	}
+	if deregisterer, ok := r.dsp.(discoveryService.Deregisterer); ok {
+		if sRep := deregisterer.Deregister(); sRep != nil && !sRep.IsSuccess() && r.logger != nil {
+			r.logger.WithError(sRep.GetError()).Error(ctx, "Cannot deregister service")
+		}
+	}
+}
+
+// register retries until the instance is registered, it returns false when stopped before that
+func (r *registration) register(ctx context.Context) bool {
+	backoff := r.backoff
+	for attempt := 1; ; attempt++ {
+		sRep := r.dsp.Register()
+		if sRep == nil || sRep.IsSuccess() {
+			r.setRegistered(true)
+			return true
+		}
+		if r.logger != nil {
+			r.logger.WithError(sRep.GetError()).Warn(ctx, "Service registration attempt %d failed, retrying in %s", attempt, backoff)
+		}
+		if !sleep(ctx, backoff) {
+			return false
+		}
+		backoff = nextBackoff(backoff, r.maxBackoff)
+	}
+}
+
+// heartbeat renews the registration until it fails or the registration is stopped
+func (r *registration) heartbeat(ctx context.Context) {
+	heartbeater, ok := r.dsp.(discoveryService.Heartbeater)
+	if !ok || heartbeater.HeartbeatInterval() <= 0 {
+		<-ctx.Done()
+		return
+	}
+	for sleep(ctx, heartbeater.HeartbeatInterval()) {
+		if sRep := heartbeater.Heartbeat(); sRep != nil && !sRep.IsSuccess() {
+			if r.logger != nil {
+				r.logger.WithError(sRep.GetError()).Warn(ctx, "Service heartbeat failed, registering again")
+			}
+			r.setRegistered(false)
+			return
+		}
+	}
+}
+
+func (r *registration) setRegistered(registered bool) {
+	r.mu.Lock()
+	r.registered = registered
+	r.mu.Unlock()
+}
+
+// nextBackoff doubles the backoff adding up to 20% jitter, it never exceeds max
+func nextBackoff(backoff, max time.Duration) time.Duration {
+	backoff *= 2
+	if jitter := int64(backoff) / 5; jitter > 0 {
+		backoff += time.Duration(rand.Int63n(jitter))
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/servicereply/status"
+	"github.com/orchestd/servicereply/types"
+	"github.com/orchestd/transport/replyCodec"
+	"github.com/orchestd/transport/replyTypes"
+	"net/http"
+)
+
+const (
+	replyContentTypesKey = "replyContentTypes"
+	replyTypeKey         = "replyType"
+)
+
+// ReplyTypeFromContext returns the type and status of the reply written by GinSuccessReply or GinErrorReply, so
+// router interceptors can report it after c.Next()
+func ReplyTypeFromContext(c *gin.Context) (types.ReplyType, status.Status, bool) {
+	val, _ := c.Get(replyTypeKey)
+	et, ok := val.(types.ReplyType)
+	if !ok {
+		return "", "", false
+	}
+	return et, replyTypes.GetStatus(&et), true
+}
+
+// ServedReply returns the http code, reply type and status a request was answered with, for router interceptors
+// recording requests after c.Next(). Interceptors should record from a defer so panicking requests are recorded
+// too: with panicking set the reply is the internal error Recovery answers with, unless a reply was already written.
+func ServedReply(c *gin.Context, panicking bool) (int, types.ReplyType, status.Status, bool) {
+	if panicking && !c.Writer.Written() {
+		et := types.InternalServiceErrorReplyType
+		return http.StatusInternalServerError, et, replyTypes.GetStatus(&et), true
+	}
+	et, st, ok := ReplyTypeFromContext(c)
+	return c.Writer.Status(), et, st, ok
+}
+
+func setReplyType(c *gin.Context, et *types.ReplyType) {
+	if et == nil {
+		c.Set(replyTypeKey, types.SuccessReplyType)
+		return
+	}
+	c.Set(replyTypeKey, *et)
+}
+
+func replyContentTypesInterceptor(contentTypes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(contentTypes) > 0 {
+			c.Set(replyContentTypesKey, contentTypes)
+		}
+		c.Next()
+	}
+}
+
+// normalizeReplyContentTypes resolves the aliases of the allowed content types, e.g. text/xml, to the content types
+// of their codecs so they match the Accept header
+func normalizeReplyContentTypes(contentTypes []string) ([]string, error) {
+	normalized := make([]string, 0, len(contentTypes))
+	for _, ct := range contentTypes {
+		if !replyCodec.IsSupported(ct) {
+			return nil, fmt.Errorf("unsupported reply content type %s", ct)
+		}
+		normalized = append(normalized, replyCodec.Normalize(ct))
+	}
+	return normalized, nil
+}
+
+// writeReply encodes the envelope in the format negotiated from the Accept header, JSON replies are rendered by
+// gin as before
+func writeReply(c *gin.Context, code int, res servicereply.Response) {
+	allowed := c.GetStringSlice(replyContentTypesKey)
+	codec := replyCodec.Negotiate(c.GetHeader("Accept"), allowed)
+	if codec.ContentType() == replyCodec.MIMEJSON {
+		c.JSON(code, res)
+		return
+	}
+	b, err := codec.Marshal(res)
+	if err != nil {
+		c.Errors = append(c.Errors, &gin.Error{Err: err, Type: gin.ErrorTypePrivate})
+		c.JSON(code, res)
+		return
+	}
+	c.Data(code, codec.ContentType(), b)
+}
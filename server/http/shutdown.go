@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const serverStateKey = "serverState"
+
+// serverState tracks readiness and in-flight requests of a server so it can be drained before shutting down
+type serverState struct {
+	draining int32
+	nextId   uint64
+	inFlight sync.Map
+}
+
+type inFlightRequest struct {
+	Method  string
+	Path    string
+	Caller  string
+	Started time.Time
+}
+
+func newServerState() *serverState {
+	return &serverState{}
+}
+
+func (s *serverState) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+func (s *serverState) startDraining() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+func (s *serverState) interceptor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(serverStateKey, s)
+		id := atomic.AddUint64(&s.nextId, 1)
+		s.inFlight.Store(id, inFlightRequest{
+			Method:  c.Request.Method,
+			Path:    c.Request.URL.Path,
+			Caller:  c.GetHeader("Caller"),
+			Started: time.Now(),
+		})
+		defer s.inFlight.Delete(id)
+		c.Next()
+	}
+}
+
+// logUnfinished reports the requests that were still running when the server was shut down
+func (s *serverState) logUnfinished(ctx context.Context, logger log.Logger) {
+	s.inFlight.Range(func(_, value interface{}) bool {
+		req := value.(inFlightRequest)
+		logger.Warn(ctx, "Request %s %s from %q didn't finish before shutdown, running for %s", req.Method, req.Path,
+			req.Caller, time.Since(req.Started))
+		return true
+	})
+}
+
+// drain waits for the drain period so load balancers notice the server is no longer ready, returning early when
+// the stop context is done
+func drain(ctx context.Context, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	t := time.NewTimer(period)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,151 @@
+package http_test
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	logDep "github.com/orchestd/log"
+	"github.com/orchestd/servicereply"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reachableDiscovery records whether the server still accepted connections when it was deregistered
+type reachableDiscovery struct {
+	mu        sync.Mutex
+	address   string
+	reachable bool
+	called    bool
+}
+
+func (d *reachableDiscovery) Register() servicereply.ServiceReply {
+	return servicereply.NewNil()
+}
+
+func (d *reachableDiscovery) GetAddress(string) servicereply.ServiceReply {
+	return servicereply.NewNil()
+}
+
+func (d *reachableDiscovery) Deregister() servicereply.ServiceReply {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	conn, err := net.DialTimeout("tcp", d.address, time.Second)
+	if err == nil {
+		conn.Close()
+	}
+	d.called, d.reachable = true, err == nil
+	return servicereply.NewNil()
+}
+
+func Test_GracefulShutdown(t *testing.T) {
+	var started, outcome int32
+	start := func(t *testing.T, logger *transportTest.Logger, dsp *reachableDiscovery) *fx.App {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			router := serverHttp.Builder().SetPort("0").SetLogger(logger).SetDiscoveryServiceProvider(dsp).
+				SetDrainPeriod(10 * time.Millisecond).AddListenHooks(func(addr net.Addr) {
+				dsp.address = "127.0.0.1:" + portOf(addr)
+			}).Build(lc)
+			router.GET("/stream", serverHttp.SSEHandleFunc(func(c context.Context, req struct{}, events chan<- int) servicereply.ServiceReply {
+				atomic.AddInt32(&started, 1)
+				<-c.Done()
+				return nil
+			}, 0))
+			router.GET("/slow", func(c *gin.Context) {
+				atomic.AddInt32(&started, 1)
+				time.Sleep(500 * time.Millisecond)
+			})
+			router.GET("/wait", func(c *gin.Context) {
+				atomic.AddInt32(&started, 1)
+				select {
+				case <-c.Request.Context().Done():
+					atomic.StoreInt32(&outcome, -1)
+				case <-time.After(300 * time.Millisecond):
+					atomic.StoreInt32(&outcome, 1)
+				}
+			})
+		}))
+		if err := app.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+	request := func(url string) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if res, err := http.Get(url); err == nil {
+				ioutil.ReadAll(res.Body)
+				res.Body.Close()
+			}
+		}()
+		for deadline := time.Now().Add(2 * time.Second); atomic.LoadInt32(&started) == 0 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		return done
+	}
+
+	convey.Convey("Given a server streaming to a client", t, func() {
+		atomic.StoreInt32(&started, 0)
+		logger, dsp := transportTest.NewLogger(), &reachableDiscovery{}
+		app := start(t, logger, dsp)
+		done := request("http://" + dsp.address + "/stream")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		begin := time.Now()
+		convey.So(app.Stop(ctx), convey.ShouldBeNil)
+		convey.So(time.Since(begin), convey.ShouldBeLessThan, time.Second)
+		<-done
+
+		convey.Convey("it was deregistered before it stopped accepting connections", func() {
+			dsp.mu.Lock()
+			defer dsp.mu.Unlock()
+			convey.So(dsp.called, convey.ShouldBeTrue)
+			convey.So(dsp.reachable, convey.ShouldBeTrue)
+		})
+	})
+
+	convey.Convey("Given a request finishing before the stop timeout", t, func() {
+		atomic.StoreInt32(&started, 0)
+		atomic.StoreInt32(&outcome, 0)
+		logger, dsp := transportTest.NewLogger(), &reachableDiscovery{}
+		app := start(t, logger, dsp)
+		done := request("http://" + dsp.address + "/wait")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		convey.So(app.Stop(ctx), convey.ShouldBeNil)
+		<-done
+		convey.So(atomic.LoadInt32(&outcome), convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("Given a request outlasting the stop timeout", t, func() {
+		atomic.StoreInt32(&started, 0)
+		logger, dsp := transportTest.NewLogger(), &reachableDiscovery{}
+		app := start(t, logger, dsp)
+		done := request("http://" + dsp.address + "/slow")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		convey.So(app.Stop(ctx), convey.ShouldNotBeNil)
+		<-done
+
+		var unfinished []string
+		for _, entry := range logger.Find(logDep.WarnLevel) {
+			if strings.Contains(entry.Message, "didn't finish") {
+				unfinished = append(unfinished, entry.Message)
+			}
+		}
+		convey.So(unfinished, convey.ShouldHaveLength, 1)
+		convey.So(unfinished[0], convey.ShouldContainSubstring, "GET /slow")
+	})
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	SSEEventEnd   = "end"
+	SSEEventError = "error"
+)
+
+// SSEHandleFunc streams the events a handler sends on its channel as text/event-stream frames, each frame carries
+// an incrementing id and the json encoded event as data. The request struct is bound like in HandleFunc.
+//
+// The handler owns the sending side of the channel but not its closing, the stream ends when the handler returns:
+// an "end" event is sent on success, otherwise an "error" event carrying the servicereply.Response of the returned
+// error. When the client disconnects or the server stops the handler's context is cancelled, so handlers must
+// select on ctx.Done() while sending. A retry above zero is sent as the client's reconnection hint.
+//
+// Note that the server WriteTimeout also applies to the stream.
+func SSEHandleFunc[Req, Event any](mFunction func(c context.Context, req Req, events chan<- Event) servicereply.ServiceReply,
+	retry time.Duration) func(context *gin.Context) {
+	return func(ginCtx *gin.Context) {
+		var req Req
+		if err := bindRequest(ginCtx, &req); err != nil {
+			GinErrorReply(ginCtx, err, nil)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ginCtx.Request.Context())
+		defer cancel()
+		events := make(chan Event)
+		done := make(chan servicereply.ServiceReply, 1)
+		go func() {
+			defer close(events)
+			// the handler runs outside the gin chain, its panics are replied here as no recovery can reach them
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					done <- servicereply.NewInternalServiceError(fmt.Errorf("panic: %v", recovered)).
+						WithLogMessage(fmt.Sprintf("recovered from panic in sse handler: %s", debug.Stack()))
+				}
+			}()
+			done <- mFunction(ctx, req, events)
+		}()
+		// once we stop reading the handler may still be sending, drain until it returns
+		drain := func() {
+			cancel()
+			go func() {
+				for range events {
+				}
+			}()
+		}
+
+		w := ginCtx.Writer
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if retry > 0 {
+			fmt.Fprintf(w, "retry: %d\n\n", retry.Milliseconds())
+		}
+		w.Flush()
+
+		id := 0
+		stopping := streamsStop(ginCtx.Request.Context())
+		for {
+			select {
+			case <-stopping:
+				// the server stops, the handler returns on its cancelled context and the stream ends normally
+				cancel()
+				stopping = nil
+			case <-ginCtx.Request.Context().Done():
+				drain()
+				return
+			case event, ok := <-events:
+				if !ok {
+					err := <-done
+					if ginCtx.Request.Context().Err() != nil {
+						return
+					}
+					if err != nil && !err.IsSuccess() {
+						writeSSEReply(ginCtx, id+1, SSEEventError, errorResponse(ginCtx, err, nil))
+					} else {
+						writeSSEReply(ginCtx, id+1, SSEEventEnd, successResponse(nil))
+					}
+					return
+				}
+				id++
+				data, err := stdjson.Marshal(event)
+				if err != nil {
+					drain()
+					sErr := servicereply.NewInternalServiceError(err).WithLogMessage("Cannot marshal event")
+					writeSSEReply(ginCtx, id, SSEEventError, errorResponse(ginCtx, sErr, nil))
+					return
+				}
+				writeSSEFrame(w, id, "", data)
+			}
+		}
+	}
+}
+
+type streamsStopKey struct{}
+
+// withStreamsStop returns a context carrying the channel closed when the server stops its sse streams
+func withStreamsStop(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, streamsStopKey{}, stop)
+}
+
+// streamsStop returns the channel closed when the server stops its sse streams, nil when it never does
+func streamsStop(ctx context.Context) <-chan struct{} {
+	stop, _ := ctx.Value(streamsStopKey{}).(<-chan struct{})
+	return stop
+}
+
+func writeSSEReply(c *gin.Context, id int, event string, res servicereply.Response) {
+	data, err := stdjson.Marshal(res)
+	if err != nil {
+		c.Errors = append(c.Errors, &gin.Error{Err: err, Type: gin.ErrorTypePrivate})
+		return
+	}
+	writeSSEFrame(c.Writer, id, event, data)
+}
+
+func writeSSEFrame(w gin.ResponseWriter, id int, event string, data []byte) {
+	fmt.Fprintf(w, "id: %d\n", id)
+	if event != "" {
+		fmt.Fprintf(w, "event: %s\n", event)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	w.Flush()
+}
@@ -0,0 +1,188 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	"github.com/orchestd/transport/server"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	peerIdentityKey = "peerIdentity"
+
+	defaultTLSReloadInterval = 10 * time.Second
+)
+
+// PeerIdentity is the verified certificate a client presented
+type PeerIdentity struct {
+	CommonName  string
+	DNSNames    []string
+	URIs        []string
+	Certificate *x509.Certificate
+}
+
+// PeerIdentityFromContext returns the identity of the client of a request served over TLS, it is set only when the
+// client certificate was verified against the client authorities
+func PeerIdentityFromContext(c context.Context) (PeerIdentity, bool) {
+	identity, ok := c.Value(peerIdentityKey).(PeerIdentity)
+	return identity, ok
+}
+
+func peerIdentityInterceptor(c *gin.Context) {
+	if state := c.Request.TLS; state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		cert := state.VerifiedChains[0][0]
+		identity := PeerIdentity{CommonName: cert.Subject.CommonName, DNSNames: cert.DNSNames, Certificate: cert}
+		for _, uri := range cert.URIs {
+			identity.URIs = append(identity.URIs, uri.String())
+		}
+		c.Set(peerIdentityKey, identity)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), peerIdentityKey, identity))
+	}
+	c.Next()
+}
+
+// tlsReloader holds the tls.Config built from the files of the settings and rebuilds it when they change, so
+// certificates can be rotated without a restart
+type tlsReloader struct {
+	settings server.TLSSettings
+	logger   log.Logger
+
+	mu       sync.RWMutex
+	config   *tls.Config
+	modTimes map[string]time.Time
+
+	cancel context.CancelFunc
+}
+
+func newTLSReloader(settings server.TLSSettings, logger log.Logger) *tlsReloader {
+	if settings.ReloadInterval <= 0 {
+		settings.ReloadInterval = defaultTLSReloadInterval
+	}
+	if settings.MinVersion == 0 {
+		settings.MinVersion = tls.VersionTLS12
+	}
+	return &tlsReloader{settings: settings, logger: logger}
+}
+
+// serverConfig is the config given to the http.Server, every handshake gets the latest loaded config
+func (r *tlsReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.settings.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.config, nil
+		},
+		// never used, http.Server only requires a certificate source to be set
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return &r.config.Certificates[0], nil
+		},
+	}
+}
+
+// start loads the files and watches them until stop is called
+func (r *tlsReloader) start() error {
+	if err := r.load(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.watch(ctx)
+	return nil
+}
+
+func (r *tlsReloader) stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func (r *tlsReloader) files() []string {
+	return append([]string{r.settings.CertFile, r.settings.KeyFile}, r.settings.ClientCAFiles...)
+}
+
+func (r *tlsReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(r.settings.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.load(); err != nil {
+				if r.logger != nil {
+					r.logger.WithError(err).Error(ctx, "Cannot reload TLS certificates, serving the previous ones")
+				}
+			} else if r.logger != nil {
+				r.logger.Info(ctx, "Reloaded TLS certificates")
+			}
+		}
+	}
+}
+
+func (r *tlsReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *tlsReloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	cert, err := tls.LoadX509KeyPair(r.settings.CertFile, r.settings.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load server certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.settings.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if len(r.settings.ClientCAFiles) > 0 {
+		pool := x509.NewCertPool()
+		for _, file := range r.settings.ClientCAFiles {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("cannot read client CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificate found in client CA bundle %s", file)
+			}
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if r.settings.RequireClientCert {
+		if config.ClientCAs == nil {
+			return fmt.Errorf("client certificates can't be required without client CA bundles")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.mu.Lock()
+	r.config, r.modTimes = config, modTimes
+	r.mu.Unlock()
+	return nil
+}
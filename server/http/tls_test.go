@@ -0,0 +1,131 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/transport/server"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"go.uber.org/fx"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func startTLSServer(t *testing.T, settings server.TLSSettings) string {
+	var address string
+	app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+		router := serverHttp.Builder().SetPort("0").SetTLS(settings).AddListenHooks(func(addr net.Addr) {
+			address = "https://127.0.0.1:" + portOf(addr)
+		}).Build(lc)
+		router.GET("/peer", func(c *gin.Context) {
+			identity, _ := serverHttp.PeerIdentityFromContext(c.Request.Context())
+			c.String(http.StatusOK, identity.CommonName)
+		})
+	}))
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Stop(context.Background()) })
+	return address
+}
+
+func portOf(addr net.Addr) string {
+	_, port, _ := net.SplitHostPort(addr.String())
+	return port
+}
+
+func tlsClient(t *testing.T, caFile string, cert ...string) *http.Client {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pem)
+	config := &tls.Config{RootCAs: pool}
+	if len(cert) == 2 {
+		pair, err := tls.LoadX509KeyPair(cert[0], cert[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func get(c *http.Client, url string) (string, error) {
+	resp, err := c.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func Test_TLS(t *testing.T) {
+	certs := transportTest.NewCertificates(t)
+
+	convey.Convey("Given a server requiring client certificates", t, func() {
+		address := startTLSServer(t, server.TLSSettings{CertFile: certs.ServerCertFile, KeyFile: certs.ServerKeyFile,
+			ClientCAFiles: []string{certs.CAFile}, RequireClientCert: true})
+
+		peer, err := get(tlsClient(t, certs.CAFile, certs.ClientCertFile, certs.ClientKeyFile), address+"/peer")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(peer, convey.ShouldEqual, transportTest.ClientCommonName)
+
+		_, err = get(tlsClient(t, certs.CAFile), address+"/peer")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("Given a server whose certificate is rotated", t, func() {
+		dir := t.TempDir()
+		certFile, keyFile := dir+"/server.pem", dir+"/server-key.pem"
+		copyFile(t, certs.ServerCertFile, certFile)
+		copyFile(t, certs.ServerKeyFile, keyFile)
+		address := startTLSServer(t, server.TLSSettings{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 10 * time.Millisecond})
+
+		peer, err := get(tlsClient(t, certs.CAFile), address+"/peer")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(peer, convey.ShouldBeEmpty)
+
+		rotated := transportTest.NewCertificates(t)
+		copyFile(t, rotated.ServerCertFile, certFile)
+		copyFile(t, rotated.ServerKeyFile, keyFile)
+		// make sure the modification time changes on coarse file systems
+		future := time.Now().Add(time.Second)
+		os.Chtimes(certFile, future, future)
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if _, err = get(tlsClient(t, rotated.CAFile), address+"/peer"); err == nil || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("Given missing certificate files", t, func() {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			serverHttp.Builder().SetPort("0").SetTLS(server.TLSSettings{CertFile: "missing.pem", KeyFile: "missing-key.pem"}).Build(lc)
+		}))
+		convey.So(app.Start(context.Background()), convey.ShouldNotBeNil)
+	})
+}
+
+func copyFile(t *testing.T, from, to string) {
+	b, err := os.ReadFile(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(to, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/server"
+	"sync"
+	"time"
+)
+
+const (
+	webSocketRegistryKey = "webSocketRegistry"
+	webSocketConnKey     = "webSocketConn"
+
+	defaultWebSocketPongWait     = 60 * time.Second
+	defaultWebSocketWriteTimeout = 10 * time.Second
+)
+
+type WebSocketSettings struct {
+	// Upgrader is used to upgrade the connection, its CheckOrigin should be set for browser clients
+	Upgrader websocket.Upgrader
+	// PongWait is how long the connection may stay silent, pings are sent at 9/10 of it
+	PongWait time.Duration
+	// WriteTimeout bounds every reply, ping and close frame write
+	WriteTimeout time.Duration
+	// ReadLimit is the max size in bytes of an inbound message, no limit when zero
+	ReadLimit int64
+}
+
+// WebSocketHandleFunc serves a websocket endpoint. Every inbound text message is decoded from json into the
+// request struct, validated, and passed to mFunction; its reply is sent back in the servicereply.Response
+// envelope. The context given to mFunction lives as long as the connection and carries the values set by the
+// api interceptors on the upgrade request, use PushWebSocketReply with it to send updates at any time.
+func WebSocketHandleFunc[Req, Res any](mFunction func(c context.Context, req Req) (Res, servicereply.ServiceReply)) func(context *gin.Context) {
+	return WebSocketHandleFuncWithSettings(mFunction, WebSocketSettings{})
+}
+
+func WebSocketHandleFuncWithSettings[Req, Res any](mFunction func(c context.Context, req Req) (Res, servicereply.ServiceReply),
+	settings WebSocketSettings) func(context *gin.Context) {
+	if settings.PongWait <= 0 {
+		settings.PongWait = defaultWebSocketPongWait
+	}
+	if settings.WriteTimeout <= 0 {
+		settings.WriteTimeout = defaultWebSocketWriteTimeout
+	}
+	return func(ginCtx *gin.Context) {
+		conn, err := settings.Upgrader.Upgrade(ginCtx.Writer, ginCtx.Request, nil)
+		if err != nil {
+			// the upgrader already replied with an http error
+			ginCtx.Errors = append(ginCtx.Errors, &gin.Error{Err: err, Type: gin.ErrorTypePrivate})
+			return
+		}
+		ctx, cancel := context.WithCancel(ginCtx.Request.Context())
+		wsConn := &webSocketConn{conn: conn, cancel: cancel, writeTimeout: settings.WriteTimeout}
+		ctx = context.WithValue(ctx, webSocketConnKey, wsConn)
+		if registry, ok := ginCtx.Value(webSocketRegistryKey).(*webSocketRegistry); ok {
+			if !registry.add(wsConn) {
+				wsConn.close(websocket.CloseGoingAway, "server is shutting down")
+				return
+			}
+			defer registry.remove(wsConn)
+		}
+		defer wsConn.close(websocket.CloseNormalClosure, "")
+
+		if settings.ReadLimit > 0 {
+			conn.SetReadLimit(settings.ReadLimit)
+		}
+		conn.SetReadDeadline(time.Now().Add(settings.PongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(settings.PongWait))
+		})
+		go wsConn.keepAlive(ctx, settings.PongWait*9/10)
+
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType != websocket.TextMessage {
+				continue
+			}
+			var req Req
+			if err := stdjson.Unmarshal(data, &req); err != nil {
+				wsConn.writeErrorReply(servicereply.NewBadRequestError("invalidJson").WithError(err).WithLogMessage("Cannot parse message to struct"))
+				continue
+			}
+			if sErr := validateRequest(&req); sErr != nil {
+				wsConn.writeErrorReply(sErr)
+				continue
+			}
+			if res, sErr := mFunction(ctx, req); sErr != nil {
+				wsConn.writeReply(replyEnvelope(sErr, res))
+			} else {
+				wsConn.writeReply(successResponse(res))
+			}
+		}
+	}
+}
+
+// PushWebSocketReply sends res in a success envelope over the connection of a context given by
+// WebSocketHandleFunc
+func PushWebSocketReply(c context.Context, res interface{}) servicereply.ServiceReply {
+	wsConn, ok := c.Value(webSocketConnKey).(*webSocketConn)
+	if !ok {
+		return servicereply.NewInternalServiceError(fmt.Errorf("context doesn't belong to a websocket connection"))
+	}
+	if err := wsConn.writeReply(successResponse(res)); err != nil {
+		return servicereply.NewIoError(err).WithLogMessage("Cannot push websocket reply")
+	}
+	return nil
+}
+
+type webSocketConn struct {
+	conn         *websocket.Conn
+	cancel       context.CancelFunc
+	writeTimeout time.Duration
+	writeMu      sync.Mutex
+	closeOnce    sync.Once
+}
+
+func (w *webSocketConn) writeReply(res servicereply.Response) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	return w.conn.WriteJSON(res)
+}
+
+func (w *webSocketConn) writeErrorReply(err servicereply.ServiceReply) error {
+	return w.writeReply(replyEnvelope(err, nil))
+}
+
+func (w *webSocketConn) keepAlive(ctx context.Context, pingPeriod time.Duration) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.writeTimeout)); err != nil {
+				w.cancel()
+				return
+			}
+		}
+	}
+}
+
+// close sends a close frame before closing the connection, which unblocks the reading loop
+func (w *webSocketConn) close(code int, text string) {
+	w.closeOnce.Do(func() {
+		w.cancel()
+		w.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(w.writeTimeout))
+		w.conn.Close()
+	})
+}
+
+// webSocketRegistry tracks the open connections of a server so they can be closed on shutdown, http.Server's
+// Shutdown doesn't close hijacked connections
+type webSocketRegistry struct {
+	mu      sync.Mutex
+	conns   map[*webSocketConn]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+func newWebSocketRegistry() *webSocketRegistry {
+	return &webSocketRegistry{conns: make(map[*webSocketConn]struct{})}
+}
+
+func (r *webSocketRegistry) interceptor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(webSocketRegistryKey, r)
+		c.Next()
+	}
+}
+
+func (r *webSocketRegistry) add(conn *webSocketConn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closing {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	r.wg.Add(1)
+	return true
+}
+
+func (r *webSocketRegistry) remove(conn *webSocketConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.conns[conn]; ok {
+		delete(r.conns, conn)
+		r.wg.Done()
+	}
+}
+
+// closeAll sends a going away close frame to every open connection and waits for their handlers to return
+func (r *webSocketRegistry) closeAll(ctx context.Context) error {
+	r.mu.Lock()
+	r.closing = true
+	conns := make([]*webSocketConn, 0, len(r.conns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	r.mu.Unlock()
+	for _, conn := range conns {
+		conn.close(websocket.CloseGoingAway, "server is shutting down")
+	}
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func registerWebSocketHandlers(api gin.IRouter, handlers []server.IHandler) error {
+	for _, h := range handlers {
+		if h.GetHttpType() != server.MethodWebSocket {
+			return fmt.Errorf("websocket handler %s has http type %q", h.GetMethod(), h.GetHttpType())
+		}
+		api.GET(h.GetMethod(), h.GetHandler()...)
+	}
+	return nil
+}
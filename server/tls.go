@@ -0,0 +1,29 @@
+package server
+
+import "time"
+
+// TLSSettings makes the server serve HTTPS
+type TLSSettings struct {
+	// CertFile and KeyFile are the PEM server certificate and key, they are reloaded when the files change
+	CertFile string
+	KeyFile  string
+	// ClientCAFiles are PEM bundles of the authorities client certificates are verified against, clients may
+	// present a certificate when they are set
+	ClientCAFiles []string
+	// RequireClientCert rejects the connections of clients without a verified certificate (mTLS)
+	RequireClientCert bool
+	// MinVersion is the minimum TLS version, tls.VersionTLS12 by default
+	MinVersion uint16
+	// ReloadInterval is how often the files are checked for changes, 10s by default
+	ReloadInterval time.Duration
+}
+
+// HTTP2Settings tunes HTTP/2, which is always negotiated over TLS
+type HTTP2Settings struct {
+	// H2C accepts HTTP/2 without TLS, from clients with prior knowledge or upgrading their connection
+	H2C bool
+	// MaxConcurrentStreams is the number of concurrent streams a client may open on a connection, 250 by default
+	MaxConcurrentStreams uint32
+	// IdleTimeout closes the connections without active streams after that long
+	IdleTimeout time.Duration
+}
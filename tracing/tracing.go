@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+)
+
+// InstrumentationName names the tracers of the transport interceptors
+const InstrumentationName = "github.com/orchestd/transport"
+
+// attributes of the server spans
+const (
+	ReplyStatusKey = attribute.Key("servicereply.status")
+	ReplyTypeKey   = attribute.Key("servicereply.error_type")
+)
+
+// Propagator reads and writes the W3C traceparent and tracestate headers
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// Settings of the tracer provider, all optional
+type Settings struct {
+	// ServiceName is the service.name resource of the exported spans
+	ServiceName string
+	// Sampler decides which traces are recorded, sdktrace.ParentBased(sdktrace.AlwaysSample()) by default
+	Sampler sdktrace.Sampler
+}
+
+// Params are the dependencies NewTracerProvider takes from the fx graph. Spans are exported only when the
+// application provides a sdktrace.SpanExporter.
+type Params struct {
+	fx.In
+
+	Lifecycle fx.Lifecycle
+	Exporter  sdktrace.SpanExporter `optional:"true"`
+	Settings  *Settings             `optional:"true"`
+}
+
+// NewTracerProvider returns a no-op provider without an exporter, otherwise a provider batching the spans to the
+// exporter and flushing them when the application stops
+func NewTracerProvider(p Params) trace.TracerProvider {
+	if p.Exporter == nil {
+		return trace.NewNoopTracerProvider()
+	}
+	settings := Settings{}
+	if p.Settings != nil {
+		settings = *p.Settings
+	}
+	if settings.Sampler == nil {
+		settings.Sampler = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(p.Exporter), sdktrace.WithSampler(settings.Sampler)}
+	if settings.ServiceName != "" {
+		options = append(options, sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String(settings.ServiceName))))
+	}
+	tp := sdktrace.NewTracerProvider(options...)
+	p.Lifecycle.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return tp.Shutdown(ctx)
+		},
+	})
+	return tp
+}
+
+// Module provides the trace.TracerProvider the interceptors are built with
+var Module = fx.Provide(NewTracerProvider)
@@ -0,0 +1,155 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	clientTracing "github.com/orchestd/transport/client/http/interceptors/tracing"
+	"github.com/orchestd/transport/server"
+	"github.com/orchestd/transport/server/http"
+	serverTracing "github.com/orchestd/transport/server/http/interceptors/tracing"
+	"github.com/orchestd/transport/tracing"
+	"github.com/orchestd/transport/transportTest"
+	"github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+	"sync"
+	"testing"
+)
+
+type exporter struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (e *exporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *exporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func (e *exporter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.spans)
+}
+
+func Test_TracerProvider(t *testing.T) {
+	convey.Convey("Given an application without an exporter", t, func() {
+		var tp trace.TracerProvider
+		app := fx.New(fx.NopLogger, tracing.Module, fx.Populate(&tp))
+		convey.So(app.Err(), convey.ShouldBeNil)
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		convey.So(span.IsRecording(), convey.ShouldBeFalse)
+	})
+
+	convey.Convey("Given an application providing an exporter", t, func() {
+		exp := &exporter{}
+		var tp trace.TracerProvider
+		app := fx.New(fx.NopLogger, tracing.Module, fx.Populate(&tp),
+			fx.Provide(func() sdktrace.SpanExporter { return exp }),
+			fx.Supply(&tracing.Settings{ServiceName: "users"}))
+		convey.So(app.Start(context.Background()), convey.ShouldBeNil)
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		convey.So(span.IsRecording(), convey.ShouldBeTrue)
+		span.End()
+
+		convey.So(app.Stop(context.Background()), convey.ShouldBeNil)
+		convey.So(exp.count(), convey.ShouldEqual, 1)
+		convey.So(exp.spans[0].Resource().Attributes(), convey.ShouldContain, semconv.ServiceNameKey.String("users"))
+	})
+}
+
+func Test_Tracing(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	traced := func() server.HttpBuilder {
+		return http.Builder().AddRouterInterceptors(serverTracing.Tracing(tp))
+	}
+	var ordersClient client.HttpClient
+	cluster := transportTest.NewCluster(t, transportTest.Service{
+		Name:    "users",
+		Builder: traced,
+		Routes: func(router gin.IRouter) {
+			router.POST("/getUser", http.Handle(func(ctx context.Context, req struct{}) (interface{}, servicereply.ServiceReply) {
+				ordersClient.Get(ctx, "orders", "orders/42?expand=lines", nil, nil)
+				return nil, servicereply.NewInternalServiceError(errors.New("boom"))
+			}))
+		},
+	}, transportTest.Service{
+		Name:    "orders",
+		Builder: traced,
+		Routes: func(router gin.IRouter) {
+			router.GET("/orders/:id", func(c *gin.Context) {
+				http.GinErrorReply(c, servicereply.NewNoMatchReply("orderNotFound"), nil)
+			})
+		},
+	})
+	newClient := func() client.HttpClient {
+		httpClient, err := cluster.NewClient(clientHttp.HTTPClientBuilder().SetConfig(cluster.Config()).
+			AddInterceptors(clientTracing.Tracing(tp)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return httpClient
+	}
+	ordersClient = newClient()
+
+	convey.Convey("Given a call going through two traced services", t, func() {
+		exp.Reset()
+		ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+		newClient().Call(ctx, nil, "users", "getUser", nil, nil)
+		root.End()
+
+		spans := map[string]tracetest.SpanStub{}
+		for _, span := range exp.GetSpans() {
+			spans[span.Name] = span
+		}
+		convey.So(spans, convey.ShouldContainKey, "users/getUser")
+		convey.So(spans, convey.ShouldContainKey, "POST /getUser")
+		convey.So(spans, convey.ShouldContainKey, "orders/orders/42")
+		convey.So(spans, convey.ShouldContainKey, "GET /orders/:id")
+
+		convey.Convey("the spans are one trace", func() {
+			parent := map[string]string{
+				"users/getUser":    "root",
+				"POST /getUser":    "users/getUser",
+				"orders/orders/42": "POST /getUser",
+				"GET /orders/:id":  "orders/orders/42",
+			}
+			for name, parentName := range parent {
+				convey.So(spans[name].SpanContext.TraceID(), convey.ShouldEqual, root.SpanContext().TraceID())
+				convey.So(spans[name].Parent.SpanID(), convey.ShouldEqual, spans[parentName].SpanContext.SpanID())
+			}
+		})
+
+		convey.Convey("client spans are named without the query", func() {
+			convey.So(spans["orders/orders/42"].Attributes, convey.ShouldContain,
+				clientTracing.TargetHandlerKey.String("orders/42?expand=lines"))
+		})
+
+		convey.Convey("server spans are tagged with the reply", func() {
+			orders := spans["GET /orders/:id"]
+			convey.So(orders.Attributes, convey.ShouldContain, tracing.ReplyTypeKey.String("noMatch"))
+			convey.So(orders.Attributes, convey.ShouldContain, tracing.ReplyStatusKey.String("noMatch"))
+			convey.So(orders.Status.Code, convey.ShouldEqual, codes.Unset)
+
+			users := spans["POST /getUser"]
+			convey.So(users.Attributes, convey.ShouldContain, tracing.ReplyTypeKey.String("internalServiceError"))
+			convey.So(users.Status.Code, convey.ShouldEqual, codes.Error)
+			convey.So(spans["users/getUser"].Status.Code, convey.ShouldEqual, codes.Error)
+		})
+	})
+}
@@ -0,0 +1,170 @@
+// Package transportTest holds test helpers starting services, clients and loggers in memory. It is imported by tests
+// only.
+package transportTest
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/configurations/config/confgetter"
+	"github.com/orchestd/dependencybundler/interfaces/configuration"
+	"github.com/orchestd/transport/client"
+	clientHttp "github.com/orchestd/transport/client/http"
+	"github.com/orchestd/transport/discoveryService/memoryDiscovery"
+	"github.com/orchestd/transport/server"
+	serverHttp "github.com/orchestd/transport/server/http"
+	"go.uber.org/fx"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	startTimeout    = 15 * time.Second
+	registerTimeout = 5 * time.Second
+)
+
+// Service describes a service started by the cluster
+type Service struct {
+	Name string
+	// Instances is the number of servers started for the service, one when zero
+	Instances int
+	// Builder returns the builder of every instance, http.Builder() when nil. The port and the discovery service
+	// provider are set by the cluster.
+	Builder func() server.HttpBuilder
+	// Routes registers the handlers of an instance
+	Routes func(router gin.IRouter)
+}
+
+// Cluster runs services on ephemeral ports in the current process, they find each other through an in-memory
+// discovery service so calls between them don't depend on the environment, e.g.
+//
+//	cluster := transportTest.NewCluster(t, transportTest.Service{Name: "users", Routes: func(r gin.IRouter) {
+//		r.POST("/getUser", http.HandleFunc(getUser))
+//	}})
+//	sRep := cluster.Client().Call(ctx, req, "users", "getUser", &res, nil)
+//
+// The servers are stopped when the test ends.
+type Cluster struct {
+	registry *memoryDiscovery.Registry
+	conf     configuration.Config
+	client   client.HttpClient
+}
+
+func NewCluster(t testing.TB, services ...Service) *Cluster {
+	t.Helper()
+	c := &Cluster{registry: memoryDiscovery.NewRegistry()}
+	conf := confgetter.NewConfgetterWrapper(map[string]interface{}{})
+	c.conf = &conf
+
+	var options []fx.Option
+	expected := map[string]int{}
+	for _, service := range services {
+		instances := service.Instances
+		if instances <= 0 {
+			instances = 1
+		}
+		expected[service.Name] += instances
+		for i := 0; i < instances; i++ {
+			options = append(options, fx.Invoke(c.newInstance(service)))
+		}
+	}
+	app := fx.New(append(options, fx.NopLogger)...)
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	if err := app.Start(ctx); err != nil {
+		t.Fatalf("cannot start cluster: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+		defer cancel()
+		if err := app.Stop(ctx); err != nil {
+			t.Errorf("cannot stop cluster: %v", err)
+		}
+	})
+	// servers register in the background once they listen
+	if !c.waitRegistered(expected) {
+		t.Fatalf("services weren't registered within %s", registerTimeout)
+	}
+
+	var err error
+	if c.client, err = c.NewClient(clientHttp.HTTPClientBuilder().SetConfig(c.conf)); err != nil {
+		t.Fatalf("cannot build cluster client: %v", err)
+	}
+	return c
+}
+
+func (c *Cluster) newInstance(service Service) func(lc fx.Lifecycle) {
+	return func(lc fx.Lifecycle) {
+		provider := c.registry.Provider(service.Name)
+		builder := serverHttp.Builder()
+		if service.Builder != nil {
+			builder = service.Builder()
+		}
+		router := builder.SetPort("0").SetDiscoveryServiceProvider(provider).
+			SetRegistrationBackoff(10*time.Millisecond, 100*time.Millisecond).
+			AddListenHooks(func(addr net.Addr) {
+				if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+					provider.SetAddress("http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(tcpAddr.Port)))
+				}
+			}).Build(lc)
+		if service.Routes != nil {
+			service.Routes(router)
+		}
+	}
+}
+
+func (c *Cluster) waitRegistered(expected map[string]int) bool {
+	deadline := time.Now().Add(registerTimeout)
+	for {
+		registered := true
+		for name, count := range expected {
+			if instances, _ := c.registry.Resolve(name); len(instances) < count {
+				registered = false
+			}
+		}
+		if registered {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Client returns a client that resolves the services of the cluster
+func (c *Cluster) Client() client.HttpClient {
+	return c.client
+}
+
+// NewClient builds a client with the given builder and points it to the cluster, use it to test client
+// interceptors or options. The builder needs a configuration, Config returns the one used by the cluster.
+func (c *Cluster) NewClient(builder client.HTTPClientBuilder) (client.HttpClient, error) {
+	httpClient, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	httpClient.SetDiscoveryServiceProvider(c.registry)
+	return httpClient, nil
+}
+
+// Config returns an empty configuration
+func (c *Cluster) Config() configuration.Config {
+	return c.conf
+}
+
+// Registry returns the discovery registry of the cluster, services can be added or removed to simulate failures
+func (c *Cluster) Registry() *memoryDiscovery.Registry {
+	return c.registry
+}
+
+// Addresses returns the base urls of the running instances of a service
+func (c *Cluster) Addresses(serviceName string) []string {
+	instances, _ := c.registry.Resolve(serviceName)
+	addresses := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		addresses = append(addresses, instance.Address)
+	}
+	return addresses
+}
@@ -0,0 +1,85 @@
+package transportTest
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/orchestd/servicereply"
+	"github.com/orchestd/transport/client"
+	"github.com/orchestd/transport/server/http"
+	"github.com/smartystreets/goconvey/convey"
+	"sync/atomic"
+	"testing"
+)
+
+type GetUserReq struct {
+	Id string `json:"id"`
+}
+
+type GetUserRes struct {
+	Id       string `json:"id"`
+	Instance int32  `json:"instance"`
+}
+
+type GetOrderReq struct {
+	UserId string `json:"userId"`
+}
+
+type GetOrderRes struct {
+	User GetUserRes `json:"user"`
+}
+
+func Test_Cluster(t *testing.T) {
+	var instances int32
+	users := Service{Name: "users", Instances: 2, Routes: func(router gin.IRouter) {
+		instance := atomic.AddInt32(&instances, 1)
+		router.POST("/getUser", http.Handle(func(c context.Context, req GetUserReq) (GetUserRes, servicereply.ServiceReply) {
+			if req.Id == "" {
+				return GetUserRes{}, servicereply.NewBadRequestError("missingId")
+			}
+			return GetUserRes{Id: req.Id, Instance: instance}, nil
+		}))
+	}}
+	var ordersClient client.HttpClient
+	orders := Service{Name: "orders", Routes: func(router gin.IRouter) {
+		router.POST("/getOrder", http.Handle(func(c context.Context, req GetOrderReq) (GetOrderRes, servicereply.ServiceReply) {
+			var res GetOrderRes
+			sRep := ordersClient.Call(c, GetUserReq{Id: req.UserId}, "users", "getUser", &res.User, nil)
+			return res, sRep
+		}))
+	}}
+	cluster := NewCluster(t, users, orders)
+	ordersClient = cluster.Client()
+
+	convey.Convey("Given a cluster of users and orders services", t, func() {
+		convey.So(cluster.Addresses("users"), convey.ShouldHaveLength, 2)
+		convey.So(cluster.Addresses("orders"), convey.ShouldHaveLength, 1)
+
+		convey.Convey("calls are balanced between the instances of a service", func() {
+			seen := map[int32]bool{}
+			for i := 0; i < 4; i++ {
+				var res GetUserRes
+				sRep := cluster.Client().Call(context.Background(), GetUserReq{Id: "1"}, "users", "getUser", &res, nil)
+				convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+				convey.So(res.Id, convey.ShouldEqual, "1")
+				seen[res.Instance] = true
+			}
+			convey.So(seen, convey.ShouldHaveLength, 2)
+		})
+
+		convey.Convey("services call each other through the cluster", func() {
+			var res GetOrderRes
+			sRep := cluster.Client().Call(context.Background(), GetOrderReq{UserId: "7"}, "orders", "getOrder", &res, nil)
+			convey.So(sRep.IsSuccess(), convey.ShouldBeTrue)
+			convey.So(res.User.Id, convey.ShouldEqual, "7")
+
+			sRep = cluster.Client().Call(context.Background(), GetOrderReq{}, "orders", "getOrder", &res, nil)
+			convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+			convey.So(sRep.GetUserError(), convey.ShouldEqual, "missingId")
+		})
+
+		convey.Convey("unknown services can't be resolved", func() {
+			sRep := cluster.Client().Call(context.Background(), GetUserReq{}, "payments", "pay", nil, nil)
+			convey.So(sRep.IsSuccess(), convey.ShouldBeFalse)
+		})
+	})
+}
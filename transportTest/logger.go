@@ -0,0 +1,108 @@
+package transportTest
+
+import (
+	"context"
+	"fmt"
+	"github.com/orchestd/dependencybundler/interfaces/log"
+	logDep "github.com/orchestd/log"
+	"sync"
+)
+
+// LogEntry is a line written to a Logger
+type LogEntry struct {
+	Level   logDep.Level
+	Message string
+	Err     error
+	Fields  map[string]interface{}
+}
+
+// Logger is a test double of log.Logger, it keeps what is logged in memory so tests can check it. Like the rest of
+// transportTest it is meant for tests only, it never writes anything and keeps every line.
+type Logger struct {
+	*logFields
+}
+
+type logRecorder struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+type logFields struct {
+	recorder *logRecorder
+	err      error
+	fields   map[string]interface{}
+}
+
+// NewLogger returns a Logger that recorded nothing yet
+func NewLogger() *Logger {
+	return &Logger{&logFields{recorder: &logRecorder{}}}
+}
+
+// Entries returns the recorded lines
+func (l *Logger) Entries() []LogEntry {
+	l.recorder.mu.Lock()
+	defer l.recorder.mu.Unlock()
+	return append([]LogEntry(nil), l.recorder.entries...)
+}
+
+// Find returns the recorded lines of a level
+func (l *Logger) Find(level logDep.Level) []LogEntry {
+	var found []LogEntry
+	for _, entry := range l.Entries() {
+		if entry.Level == level {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func (l *Logger) Configuration() logDep.LoggerConfiguration {
+	return nil
+}
+
+func (f *logFields) Trace(ctx context.Context, format string, args ...interface{}) {
+	f.Custom(ctx, logDep.TraceLevel, 0, format, args...)
+}
+
+func (f *logFields) Debug(ctx context.Context, format string, args ...interface{}) {
+	f.Custom(ctx, logDep.DebugLevel, 0, format, args...)
+}
+
+func (f *logFields) Info(ctx context.Context, format string, args ...interface{}) {
+	f.Custom(ctx, logDep.InfoLevel, 0, format, args...)
+}
+
+func (f *logFields) Warn(ctx context.Context, format string, args ...interface{}) {
+	f.Custom(ctx, logDep.WarnLevel, 0, format, args...)
+}
+
+func (f *logFields) Error(ctx context.Context, format string, args ...interface{}) {
+	f.Custom(ctx, logDep.ErrorLevel, 0, format, args...)
+}
+
+func (f *logFields) Custom(ctx context.Context, level logDep.Level, skipAdditionalFrames int, format string, args ...interface{}) {
+	f.recorder.mu.Lock()
+	defer f.recorder.mu.Unlock()
+	f.recorder.entries = append(f.recorder.entries, LogEntry{Level: level, Message: fmt.Sprintf(format, args...), Err: f.err, Fields: f.fields})
+}
+
+func (f *logFields) WithError(err error) logDep.Fields {
+	return &logFields{recorder: f.recorder, err: err, fields: f.fields}
+}
+
+func (f *logFields) WithField(name string, value interface{}) logDep.Fields {
+	return f.WithFields(map[string]interface{}{name: value})
+}
+
+func (f *logFields) WithFields(fields map[string]interface{}) logDep.Fields {
+	merged := make(map[string]interface{}, len(f.fields)+len(fields))
+	for k, v := range f.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &logFields{recorder: f.recorder, err: f.err, fields: merged}
+}
+
+var _ log.Logger = (*Logger)(nil)